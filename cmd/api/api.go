@@ -1,15 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/interfaces"
+	"github.com/mafi020/social/internal/media"
 	mid "github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/scheduler"
 	"github.com/mafi020/social/internal/store"
+	"github.com/mafi020/social/internal/stream"
+	"github.com/mafi020/social/internal/trash"
+	"github.com/mafi020/social/internal/trending"
 	"go.uber.org/zap"
 )
 
@@ -20,21 +34,86 @@ type dbConfig struct {
 	maxIdleTime  string
 }
 
+type streamConfig struct {
+	pgNotify bool
+	channel  string
+}
+
+type webhooksConfig struct {
+	workerEnabled        bool
+	maxAttempts          int
+	allowPrivateNetworks bool // deliveries to loopback and private addresses, for development
+}
+
+type eventsConfig struct {
+	outboxEnabled bool
+	maxAttempts   int
+}
+
+type timelinesConfig struct {
+	fanOutMaxFollowers int
+	backfillLimit      int
+}
+
+type searchConfig struct {
+	backend   string
+	blevePath string
+}
+
+type syndicationConfig struct {
+	limit int // posts per feed
+}
+
+type mediaConfig struct {
+	backend        string
+	localPath      string
+	maxAttachments int    // per post
+	urlSecret      string // signs the URLs of the blobs served by the API
+	urlTTL         time.Duration
+	processing     media.Config
+	cleaner        media.CleanerConfig
+}
+
+type reactionsConfig struct {
+	types []string // allowed reaction types
+}
+
 type config struct {
-	port string
-	db   *dbConfig
-	env  string
+	port        string
+	baseURL     string // public URL of the API, for absolute links
+	db          *dbConfig
+	env         string
+	stream      *streamConfig
+	webhooks    *webhooksConfig
+	events      *eventsConfig
+	timelines   *timelinesConfig
+	feedRanking *dto.FeedRanking
+	trending    *trending.Config
+	search      *searchConfig
+	syndication *syndicationConfig
+	scheduler   *scheduler.Config
+	trash       *trash.Config
+	media       *mediaConfig
+	reactions   *reactionsConfig
 }
 
 type application struct {
-	config *config
-	store  store.Storage
-	logger *zap.SugaredLogger
+	config    *config
+	store     store.Storage
+	logger    *zap.SugaredLogger
+	hub       *stream.Hub
+	events    *events.Bus
+	trending  *trending.Service
+	search    interfaces.SearchIndex
+	blobs     interfaces.BlobStore
+	mediaURLs *media.URLSigner // verifies the URLs of the blobs served by the API
 }
 
+// init loads the .env file, when there is one: the environment can be set without it, as in
+// tests.
 func init() {
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatal("Error loading .env file")
 	}
 }
@@ -47,73 +126,159 @@ func (app *application) mount() http.Handler {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	// Set a timeout value on the request context (ctx), that will signal
-	// through ctx.Done() that the request has timed out and further
-	// processing should be stopped.
-	r.Use(middleware.Timeout(60 * time.Second))
-
 	r.Route("/api", func(r chi.Router) {
-		r.Get("/health", app.healthCheckHandler)
+		// Streaming connections stay open for as long as the client listens, so they are
+		// mounted outside the request timeout below.
+		r.Group(func(r chi.Router) {
+			r.Use(mid.StreamAuthMiddleware)
+			r.Get("/stream", app.streamHandler)
+			r.Get("/ws", app.websocketHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			// Set a timeout value on the request context (ctx), that will signal
+			// through ctx.Done() that the request has timed out and further
+			// processing should be stopped.
+			r.Use(middleware.Timeout(60 * time.Second))
+
+			r.Get("/health", app.healthCheckHandler)
+
+			// Syndication feeds are public, for feed readers
+			r.Get("/users/{username}/feed.rss", app.getUserRSSFeedHandler)
+			r.Get("/users/{username}/feed.atom", app.getUserAtomFeedHandler)
+			r.Get("/tags/{tag}/feed.atom", app.getTagAtomFeedHandler)
+
+			// Uploads kept on the local disk, at signed URLs handed out with the posts
+			r.Get("/media/files/*", app.getMediaFileHandler)
+
+			r.Route("/auth", func(r chi.Router) {
+				r.Post("/register", app.registerUserHandler)
+				r.Post("/login", app.loginHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(mid.AuthMiddleware)
+					r.Post("/logout", app.logoutHandler)
+				})
+			})
+
+			r.Route("/refresh", func(r chi.Router) {
+				r.Post("/", app.refreshHandler)
+			})
 
-		r.Route("/auth", func(r chi.Router) {
-			r.Post("/register", app.registerUserHandler)
-			r.Post("/login", app.loginHandler)
 			r.Group(func(r chi.Router) {
 				r.Use(mid.AuthMiddleware)
-				r.Post("/logout", app.logoutHandler)
-			})
-		})
 
-		r.Route("/refresh", func(r chi.Router) {
-			r.Post("/", app.refreshHandler)
-		})
+				// r.Route("/refresh", func(r chi.Router) {
+				// 	r.Post("/", app.refreshHandler)
+				// })
 
-		r.Group(func(r chi.Router) {
-			r.Use(mid.AuthMiddleware)
+				r.Route("/invitations", func(r chi.Router) {
+					r.Post("/", app.createInvitationHandler)
+					r.Get("/accept", app.acceptInvitationHandler)
+				})
 
-			// r.Route("/refresh", func(r chi.Router) {
-			// 	r.Post("/", app.refreshHandler)
-			// })
+				r.Route("/users", func(r chi.Router) {
+					r.Route("/{userID}", func(r chi.Router) {
+						r.Use(app.userFromRouteMiddleware)
 
-			r.Route("/invitations", func(r chi.Router) {
-				r.Post("/", app.createInvitationHandler)
-				r.Get("/accept", app.acceptInvitationHandler)
-			})
+						r.Get("/", app.getUserHandler)
+						r.Delete("/", app.deleteUserHandler)
+						r.Put("/follow", app.followUserHandler)
+						r.Put("/unfollow", app.unfollowUserHandler)
+						r.Put("/block", app.blockUserHandler)
+						r.Put("/unblock", app.unblockUserHandler)
+						r.Put("/mute", app.muteUserHandler)
+						r.Put("/unmute", app.unmuteUserHandler)
+					})
+
+					r.Group(func(r chi.Router) {
+						r.Get("/feed", app.getUserFeedHandler)
+						r.Get("/me/drafts", app.getDraftsHandler)
+						r.Get("/me/trash", app.getTrashHandler)
+					})
+				})
 
-			r.Route("/users", func(r chi.Router) {
-				r.Route("/{userID}", func(r chi.Router) {
-					r.Use(app.userFromRouteMiddleware)
+				r.Route("/posts", func(r chi.Router) {
+					r.Get("/", app.getExploreHandler)
+					r.Post("/", app.createPostHandler)
+					r.Route("/{postID}", func(r chi.Router) {
+						r.Get("/", app.getPostHandler)
+						r.Delete("/", app.deletePostHandler)
+						r.Patch("/", app.updatePostHandler)
+						r.Post("/restore", app.restorePostHandler)
+						r.Put("/reaction", app.reactToPostHandler)
+						r.Delete("/reaction", app.deletePostReactionHandler)
+						r.Get("/reactions", app.getPostReactionsHandler)
+						r.Put("/repost", app.repostHandler)
+						r.Delete("/repost", app.deleteRepostHandler)
 
-					r.Get("/", app.getUserHandler)
-					r.Delete("/", app.deleteUserHandler)
-					r.Put("/follow", app.followUserHandler)
-					r.Put("/unfollow", app.unfollowUserHandler)
+						r.Route("/revisions", func(r chi.Router) {
+							r.Get("/", app.getPostRevisionsHandler)
+							r.Get("/diff", app.getPostRevisionsDiffHandler)
+							r.Post("/{revision}/restore", app.restorePostRevisionHandler)
+						})
+					})
 				})
 
-				r.Group(func(r chi.Router) {
-					r.Get("/feed", app.getUserFeedHandler)
+				r.Post("/media", app.uploadMediaHandler)
+
+				r.Get("/search", app.searchHandler)
+
+				r.Route("/tags", func(r chi.Router) {
+					r.Get("/", app.getTagsHandler)
+					r.Get("/trending", app.getTrendingTagsHandler)
+					r.Get("/{tag}/posts", app.getTagPostsHandler)
+
+					r.Route("/denylist", func(r chi.Router) {
+						r.Use(app.moderatorMiddleware)
+
+						r.Get("/", app.getDeniedTagsHandler)
+						r.Put("/{tag}", app.denyTagHandler)
+						r.Delete("/{tag}", app.allowTagHandler)
+					})
 				})
-			})
 
-			r.Route("/posts", func(r chi.Router) {
-				r.Post("/", app.createPostHandler)
-				r.Route("/{postID}", func(r chi.Router) {
-					r.Get("/", app.getPostHandler)
-					r.Delete("/", app.deletePostHandler)
-					r.Patch("/", app.updatePostHandler)
+				r.Route("/filters", func(r chi.Router) {
+					r.Get("/", app.getFiltersHandler)
+					r.Post("/", app.createFilterHandler)
+					r.Delete("/{filterID}", app.deleteFilterHandler)
 				})
-			})
 
-			r.Route("/comments", func(r chi.Router) {
-				r.Post("/", app.createCommentHandler)
-				r.Route("/{commentID}", func(r chi.Router) {
-					r.Get("/", app.getCommentHandler)
-					r.Patch("/", app.updateCommentHandler)
-					r.Delete("/", app.deleteCommentHandler)
+				r.Route("/notifications", func(r chi.Router) {
+					r.Get("/", app.getNotificationsHandler)
+					r.Get("/unread-count", app.getUnreadNotificationsCountHandler)
+					r.Put("/read", app.markAllNotificationsReadHandler)
+					r.Put("/{notificationID}/read", app.markNotificationReadHandler)
+				})
+
+				// Webhooks feed internal tools: subscriptions receive the events of the whole site
+				r.Route("/webhooks", func(r chi.Router) {
+					r.Use(app.moderatorMiddleware)
+
+					r.Post("/", app.createWebhookHandler)
+					r.Get("/", app.getWebhooksHandler)
+					r.Route("/{webhookID}", func(r chi.Router) {
+						r.Use(app.webhookFromRouteMiddleware)
+
+						r.Get("/", app.getWebhookHandler)
+						r.Delete("/", app.deleteWebhookHandler)
+						r.Get("/deliveries", app.getWebhookDeliveriesHandler)
+					})
+				})
+
+				r.Route("/comments", func(r chi.Router) {
+					r.Post("/", app.createCommentHandler)
+					r.Route("/{commentID}", func(r chi.Router) {
+						r.Get("/", app.getCommentHandler)
+						r.Patch("/", app.updateCommentHandler)
+						r.Delete("/", app.deleteCommentHandler)
+						r.Post("/restore", app.restoreCommentHandler)
+						r.Put("/reaction", app.reactToCommentHandler)
+						r.Delete("/reaction", app.deleteCommentReactionHandler)
+						r.Get("/reactions", app.getCommentReactionsHandler)
+					})
 				})
 			})
 		})
-
 	})
 
 	return r
@@ -127,6 +292,37 @@ func (app *application) start(mux http.Handler) error {
 		ReadTimeout:  time.Second * 10,
 		IdleTimeout:  time.Minute,
 	}
+
+	// Shutdown doesn't wait for SSE streams to end nor track hijacked WebSocket connections;
+	// closing the hub makes their handlers return.
+	server.RegisterOnShutdown(func() {
+		if err := app.hub.Close(); err != nil {
+			app.logger.Warnw("failed to close stream hub", "error", err)
+		}
+	})
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-quit
+
+		app.logger.Infow("Shutting down server", "signal", sig.String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		shutdownErr <- server.Shutdown(ctx)
+	}()
+
 	app.logger.Infow("Server running on port "+app.config.port, "env", app.config.env)
-	return server.ListenAndServe()
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err := <-shutdownErr; err != nil {
+		return err
+	}
+
+	app.logger.Infow("Server stopped")
+	return nil
 }
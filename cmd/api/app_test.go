@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/media"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/search"
+	"github.com/mafi020/social/internal/store"
+	"github.com/mafi020/social/internal/stream"
+	"go.uber.org/zap"
+)
+
+// newTestApplication wires the application to the database of TEST_PSQL_URL, migrated to the
+// latest version, the way main does with the default configuration. Tests needing it are
+// skipped without a database.
+func newTestApplication(t *testing.T) (*application, *sql.DB) {
+	t.Helper()
+
+	url := os.Getenv("TEST_PSQL_URL")
+	if url == "" {
+		t.Skip("TEST_PSQL_URL is not set")
+	}
+
+	conn, err := db.New(url, 5, 5, "1m")
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+
+	storage := store.NewPostgresStorage(conn)
+	logger := zap.NewNop().Sugar()
+
+	mediaURLs := media.NewURLSigner("test", time.Hour)
+	blobs, err := media.NewLocalStore(t.TempDir(), "http://localhost/api/media/files", mediaURLs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		config: &config{
+			baseURL:     "http://localhost",
+			env:         "test",
+			webhooks:    &webhooksConfig{},
+			events:      &eventsConfig{},
+			timelines:   &timelinesConfig{fanOutMaxFollowers: 10000, backfillLimit: 200},
+			feedRanking: &dto.FeedRanking{},
+			media:       &mediaConfig{maxAttachments: 4, urlTTL: time.Hour},
+			reactions:   &reactionsConfig{types: []string{"like"}},
+		},
+		store:     storage,
+		logger:    logger,
+		hub:       stream.NewHub(64),
+		events:    events.NewBus(logger),
+		search:    search.NewPostgresIndex(storage.Search),
+		blobs:     blobs,
+		mediaURLs: mediaURLs,
+	}
+	app.registerEventHandlers()
+
+	t.Cleanup(func() {
+		app.events.Wait()
+		app.hub.Close()
+		conn.Close()
+	})
+
+	return app, conn
+}
+
+// createTestUser creates a user with a unique username starting with the prefix.
+func createTestUser(t *testing.T, app *application, prefix string) *dto.User {
+	t.Helper()
+
+	name := fmt.Sprintf("%s%d", prefix, time.Now().UnixNano())
+	user := &dto.User{UserName: name, Email: name + "@example.com", Password: "not a hash"}
+	if err := app.store.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+// serve runs the handler with the request authenticated as the user, with ctx values set the
+// way the route middlewares would.
+func serve(t *testing.T, handler http.HandlerFunc, method string, body any, userID int64, values map[any]any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := httptest.NewRequest(method, "/", &buf)
+	ctx := context.WithValue(r.Context(), middleware.UserIDKey, userID)
+	for k, v := range values {
+		ctx = context.WithValue(ctx, k, v)
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, r.WithContext(ctx))
+	return w
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
-	"strconv"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/mafi020/social/internal/dto"
 	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/markdown"
+	"github.com/mafi020/social/internal/middleware"
 	"github.com/mafi020/social/internal/utils"
 )
 
@@ -31,13 +33,26 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 
 	ctx := r.Context()
 	comment := &dto.Comment{
-		PostID:  payload.PostID,
-		UserID:  payload.UserID,
-		Content: payload.Content,
+		PostID:      payload.PostID,
+		UserID:      payload.UserID,
+		Content:     payload.Content,
+		ContentHTML: markdown.Render(payload.Content),
 	}
 
-	if err := app.store.Comments.Create(ctx, comment); err != nil {
-		app.internalServerError(w, r, err)
+	post, err := app.store.Posts.GetByID(ctx, comment.PostID, middleware.GetAuthUserIDFromContext(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, errs.ErrForbidden):
+			app.forbiddenError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if post.RepostOfID != nil {
+		app.badRequestError(w, r, errors.New("reposts cannot be commented on, comment on the original post"))
 		return
 	}
 
@@ -52,6 +67,24 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 		UserName: userData.UserName,
 	}
 
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := app.store.Comments.Create(ctx, comment); err != nil {
+			return err
+		}
+		mentions, added, err := app.saveMentions(ctx, comment.PostID, &comment.ID, comment.Content)
+		if err != nil {
+			return err
+		}
+		comment.Mentions = mentions
+		if err := app.events.Publish(ctx, events.CommentCreated{Comment: *comment, PostAuthorID: post.UserID, PostVisibility: post.Visibility}); err != nil {
+			return err
+		}
+		return app.publishMentions(ctx, comment, added)
+	}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := utils.JSONResponse(w, http.StatusCreated, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -59,23 +92,19 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 }
 
 func (app *application) getCommentHandler(w http.ResponseWriter, r *http.Request) {
-	paramCommentID := chi.URLParam(r, "commentID")
-	commentId, err := strconv.ParseInt(paramCommentID, 10, 64)
-	if err != nil {
-		app.badRequestError(w, r, errors.New("invalid comment ID"))
+	comment, ok := app.readableCommentFromRoute(w, r)
+	if !ok {
 		return
 	}
 
 	ctx := r.Context()
 
-	comment, err := app.store.Comments.GetByID(ctx, commentId)
-	if err != nil {
-		switch {
-		case errors.Is(err, errs.ErrNotFound):
-			app.notFoundError(w, r, err)
-		default:
-			app.internalServerError(w, r, err)
-		}
+	if err := app.loadCommentMentions(ctx, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.loadCommentReactions(ctx, middleware.GetAuthUserIDFromContext(r), comment); err != nil {
+		app.internalServerError(w, r, err)
 		return
 	}
 
@@ -89,38 +118,43 @@ type updateCommentPayload struct {
 }
 
 func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
-	paramCommentID := chi.URLParam(r, "commentID")
-	commentID, err := strconv.ParseInt(paramCommentID, 10, 64)
-	if err != nil {
-		app.badRequestError(w, r, errors.New("invalid comment ID"))
+	comment, ok := app.readableCommentFromRoute(w, r)
+	if !ok {
 		return
 	}
+
 	var payload updateCommentPayload
 	if err := utils.ReadJSON(r, &payload); err != nil {
 		app.badRequestError(w, r, err)
+		return
 	}
 
 	validationErros := utils.ValidateStruct(&payload)
 	if validationErros != nil {
 		app.failedValidationError(w, r, validationErros)
-	}
-	ctx := r.Context()
-
-	comment, err := app.store.Comments.GetByID(ctx, commentID)
-	if err != nil {
-		if errors.Is(err, errs.ErrNotFound) {
-			app.notFoundError(w, r, err)
-		} else {
-			app.internalServerError(w, r, err)
-		}
 		return
 	}
+	ctx := r.Context()
 
 	if payload.Content != nil {
 		comment.Content = *payload.Content
+		comment.ContentHTML = markdown.Render(comment.Content)
 	}
 
-	if err := app.store.Comments.Update(ctx, comment); err != nil {
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := app.store.Comments.Update(ctx, comment); err != nil {
+			return err
+		}
+		mentions, added, err := app.saveMentions(ctx, comment.PostID, &comment.ID, comment.Content)
+		if err != nil {
+			return err
+		}
+		comment.Mentions = mentions
+		if err := app.events.Publish(ctx, events.CommentUpdated{Comment: *comment}); err != nil {
+			return err
+		}
+		return app.publishMentions(ctx, comment, added)
+	}); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -131,13 +165,18 @@ func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Requ
 
 }
 func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
-	paramCommentID := chi.URLParam(r, "commentID")
-	commentID, err := strconv.ParseInt(paramCommentID, 10, 64)
-	if err != nil {
-		app.badRequestError(w, r, errors.New("invalid comment ID"))
+	comment, ok := app.readableCommentFromRoute(w, r)
+	if !ok {
+		return
 	}
 	ctx := r.Context()
-	if err := app.store.Comments.Delete(ctx, commentID); err != nil {
+
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := app.store.Comments.Delete(ctx, comment.ID); err != nil {
+			return err
+		}
+		return app.events.Publish(ctx, events.CommentDeleted{CommentID: comment.ID, PostID: comment.PostID})
+	}); err != nil {
 		switch {
 		case errors.Is(err, errs.ErrNotFound):
 			app.notFoundError(w, r, err)
@@ -152,3 +191,16 @@ func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 }
+
+// publishMentions publishes the users newly mentioned by the comment.
+func (app *application) publishMentions(ctx context.Context, comment *dto.Comment, userIDs []int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return app.events.Publish(ctx, events.UsersMentioned{
+		ActorID:   comment.UserID,
+		PostID:    comment.PostID,
+		CommentID: &comment.ID,
+		UserIDs:   userIDs,
+	})
+}
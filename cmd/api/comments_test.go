@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+)
+
+// commentRoute returns the ctx values routing a request to the comment of the post.
+func commentRoute(postID, commentID int64) map[any]any {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("postID", strconv.FormatInt(postID, 10))
+	rctx.URLParams.Add("commentID", strconv.FormatInt(commentID, 10))
+	return map[any]any{chi.RouteCtxKey: rctx}
+}
+
+func TestCommentsOfPrivatePostsAreForbidden(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	author := createTestUser(t, app, "author")
+	stranger := createTestUser(t, app, "stranger")
+
+	w := serve(t, app.createPostHandler, http.MethodPost, createPostPayload{
+		Title:      "Private",
+		Content:    "For my eyes only",
+		Visibility: dto.VisibilityPrivate,
+		UserID:     author.ID,
+	}, author.ID, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create post: got status %d: %s", w.Code, w.Body)
+	}
+	var post dto.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &post); err != nil {
+		t.Fatal(err)
+	}
+
+	comment := &dto.Comment{PostID: post.ID, UserID: author.ID, Content: "A note", ContentHTML: "<p>A note</p>"}
+	if err := app.store.Comments.Create(context.Background(), comment); err != nil {
+		t.Fatal(err)
+	}
+	route := commentRoute(post.ID, comment.ID)
+
+	if w := serve(t, app.getCommentHandler, http.MethodGet, nil, author.ID, route); w.Code != http.StatusOK {
+		t.Errorf("get comment as the author: got status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	content := "Edited"
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    any
+	}{
+		{"get", app.getCommentHandler, http.MethodGet, nil},
+		{"update", app.updateCommentHandler, http.MethodPatch, updateCommentPayload{Content: &content}},
+		{"delete", app.deleteCommentHandler, http.MethodDelete, nil},
+	} {
+		if w := serve(t, tc.handler, tc.method, tc.body, stranger.ID, route); w.Code != http.StatusForbidden {
+			t.Errorf("%s comment of another user's private post: got status %d, want %d: %s", tc.name, w.Code, http.StatusForbidden, w.Body)
+		}
+	}
+
+	got, err := app.store.Comments.GetByID(context.Background(), comment.ID)
+	if err != nil {
+		t.Fatalf("the comment is gone: %v", err)
+	}
+	if got.Content != comment.Content {
+		t.Errorf("got content %q, want the comment unchanged", got.Content)
+	}
+
+	app.events.Wait()
+}
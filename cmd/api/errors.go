@@ -30,3 +30,8 @@ func (app *application) unAuthorizedError(w http.ResponseWriter, r *http.Request
 	app.logger.Warnw("unAuthorized", "method", r.Method, "path", r.URL.Path, "errors", err)
 	utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]string{"message": err.Error()})
 }
+
+func (app *application) forbiddenError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("forbidden", "method", r.Method, "path", r.URL.Path, "errors", err)
+	utils.JSONErrorResponse(w, http.StatusForbidden, map[string]string{"message": err.Error()})
+}
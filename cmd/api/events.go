@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/env"
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/search"
+	"github.com/mafi020/social/internal/stream"
+	"github.com/mafi020/social/internal/templates"
+	"github.com/mafi020/social/internal/utils"
+	"github.com/mafi020/social/internal/webhooks"
+)
+
+// registerEventHandlers wires the side effects of domain events. Handlers only publish events.
+//
+// Notifications and webhook deliveries are written synchronously, real-time pushes and emails
+// run asynchronously. With the outbox enabled a failed handler makes the relay retry it, and
+// only it: handlers are named for the relay to record those that handled the event.
+func (app *application) registerEventHandlers() {
+	bus := app.events
+
+	bus.Subscribe(events.NameUserFollowed, "notifications", app.notificationEventHandler)
+	bus.Subscribe(events.NameCommentCreated, "notifications", app.notificationEventHandler)
+	bus.Subscribe(events.NameInvitationAccepted, "notifications", app.notificationEventHandler)
+	bus.Subscribe(events.NamePostCreated, "notifications", app.notificationEventHandler)
+	bus.Subscribe(events.NameUsersMentioned, "notifications", app.notificationEventHandler)
+
+	bus.Subscribe(events.NamePostCreated, "timelines", app.timelineEventHandler)
+	bus.Subscribe(events.NameUserFollowed, "timelines", app.timelineEventHandler)
+	bus.Subscribe(events.NameUserUnfollowed, "timelines", app.timelineEventHandler)
+
+	bus.Subscribe(events.NamePostCreated, "search", app.searchIndexEventHandler)
+	bus.Subscribe(events.NamePostUpdated, "search", app.searchIndexEventHandler)
+	bus.Subscribe(events.NamePostDeleted, "search", app.searchIndexEventHandler)
+	bus.Subscribe(events.NamePostRestored, "search", app.searchIndexEventHandler)
+	bus.Subscribe(events.NameCommentCreated, "search", app.searchIndexEventHandler)
+	bus.Subscribe(events.NameCommentUpdated, "search", app.searchIndexEventHandler)
+	bus.Subscribe(events.NameCommentDeleted, "search", app.searchIndexEventHandler)
+	bus.Subscribe(events.NameCommentRestored, "search", app.searchIndexEventHandler)
+
+	bus.Subscribe(events.NamePostCreated, "webhooks", app.webhookEventHandler)
+	bus.Subscribe(events.NameCommentCreated, "webhooks", app.webhookEventHandler)
+	bus.Subscribe(events.NameUserFollowed, "webhooks", app.webhookEventHandler)
+	bus.Subscribe(events.NameInvitationAccepted, "webhooks", app.webhookEventHandler)
+
+	bus.SubscribeAsync(events.NamePostCreated, "stream", app.streamEventHandler)
+	bus.SubscribeAsync(events.NamePostDeleted, "stream", app.streamEventHandler)
+	bus.SubscribeAsync(events.NameCommentCreated, "stream", app.streamEventHandler)
+	bus.SubscribeAsync(events.NameCommentUpdated, "stream", app.streamEventHandler)
+	bus.SubscribeAsync(events.NameCommentDeleted, "stream", app.streamEventHandler)
+
+	bus.SubscribeAsync(events.NameInvitationCreated, "invitation_email", app.invitationEmailEventHandler)
+}
+
+func (app *application) notificationEventHandler(ctx context.Context, evt events.Event) error {
+	switch e := evt.(type) {
+	case events.UserFollowed:
+		return app.notify(ctx, &dto.Notification{
+			UserID:  e.UserID,
+			ActorID: &e.FollowerID,
+			Type:    dto.NotificationFollow,
+		})
+
+	case events.CommentCreated:
+		return app.notify(ctx, &dto.Notification{
+			UserID:    e.PostAuthorID,
+			ActorID:   &e.Comment.UserID,
+			Type:      dto.NotificationComment,
+			PostID:    &e.Comment.PostID,
+			CommentID: &e.Comment.ID,
+		})
+
+	// Mentions made while the post was a draft or scheduled are notified once it is published
+	case events.PostCreated:
+		mentions, err := app.store.Mentions.GetByPostIDs(ctx, []int64{e.Post.ID})
+		if err != nil {
+			return err
+		}
+		userIDs := make([]int64, len(mentions))
+		for i, m := range mentions {
+			userIDs[i] = m.UserID
+		}
+		return app.notifyMentions(ctx, e.Post.UserID, e.Post.ID, nil, userIDs)
+
+	case events.UsersMentioned:
+		return app.notifyMentions(ctx, e.ActorID, e.PostID, e.CommentID, e.UserIDs)
+
+	case events.InvitationAccepted:
+		data, err := json.Marshal(map[string]string{"email": e.Invitation.Email})
+		if err != nil {
+			return err
+		}
+		return app.notify(ctx, &dto.Notification{
+			UserID: e.Invitation.InviterID,
+			Type:   dto.NotificationInvitationAccepted,
+			Data:   data,
+		})
+	}
+	return nil
+}
+
+// timelineEventHandler keeps the home timelines up to date. Posts that could not be fanned out
+// are still pulled into the feeds, a failure only makes reading them slower.
+func (app *application) timelineEventHandler(ctx context.Context, evt events.Event) error {
+	switch e := evt.(type) {
+	case events.PostCreated:
+		_, err := app.store.Timelines.FanOut(ctx, &e.Post, app.config.timelines.fanOutMaxFollowers)
+		return err
+
+	case events.UserFollowed:
+		return app.store.Timelines.Backfill(ctx, e.FollowerID, e.UserID, app.config.timelines.backfillLimit)
+
+	case events.UserUnfollowed:
+		return app.store.Timelines.RemoveAuthor(ctx, e.FollowerID, e.UserID)
+	}
+	return nil
+}
+
+// searchIndexEventHandler feeds the search index, for the backends not reading the store.
+func (app *application) searchIndexEventHandler(ctx context.Context, evt events.Event) error {
+	switch e := evt.(type) {
+	// Reposts have no content of their own to search
+	case events.PostCreated:
+		if e.Post.RepostOfID != nil {
+			return nil
+		}
+		return app.search.Index(ctx, search.PostDocument(e.Post))
+
+	case events.PostUpdated:
+		return app.search.Index(ctx, search.PostDocument(e.Post))
+
+	case events.PostDeleted:
+		return app.search.Delete(ctx, dto.SearchTypePosts, e.PostID)
+
+	case events.PostRestored:
+		if e.Post.Status != dto.PostStatusPublished {
+			return nil
+		}
+		return app.search.Index(ctx, search.PostDocument(e.Post))
+
+	case events.CommentCreated:
+		return app.search.Index(ctx, search.CommentDocument(e.Comment, e.PostAuthorID))
+
+	case events.CommentUpdated:
+		return app.indexComment(ctx, e.Comment)
+
+	case events.CommentRestored:
+		return app.indexComment(ctx, e.Comment)
+
+	case events.CommentDeleted:
+		return app.search.Delete(ctx, dto.SearchTypeComments, e.CommentID)
+	}
+	return nil
+}
+
+// indexComment indexes the comment unless its post is gone.
+func (app *application) indexComment(ctx context.Context, comment dto.Comment) error {
+	posts, err := app.store.Posts.GetByIDs(ctx, []int64{comment.PostID})
+	if err != nil || len(posts) == 0 {
+		return err
+	}
+	return app.search.Index(ctx, search.CommentDocument(comment, posts[0].UserID))
+}
+
+func (app *application) webhookEventHandler(ctx context.Context, evt events.Event) error {
+	switch e := evt.(type) {
+	// Webhooks are delivered to every subscriber, so only public content is sent
+	case events.PostCreated:
+		if e.Post.Visibility != dto.VisibilityPublic {
+			return nil
+		}
+		return app.enqueueWebhook(ctx, webhooks.EventPostCreated, e.Post)
+
+	case events.CommentCreated:
+		if e.PostVisibility != "" && e.PostVisibility != dto.VisibilityPublic {
+			return nil
+		}
+		return app.enqueueWebhook(ctx, webhooks.EventCommentCreated, e.Comment)
+
+	case events.UserFollowed:
+		return app.enqueueWebhook(ctx, webhooks.EventUserFollowed, e)
+
+	// Without the email of the invitee, tools look it up by invitation_id
+	case events.InvitationAccepted:
+		return app.enqueueWebhook(ctx, webhooks.EventInvitationAccepted, map[string]any{
+			"invitation_id": e.Invitation.ID,
+			"inviter_id":    e.Invitation.InviterID,
+		})
+	}
+	return nil
+}
+
+func (app *application) streamEventHandler(ctx context.Context, evt events.Event) error {
+	switch e := evt.(type) {
+	case events.PostCreated:
+		app.publishPostCreated(ctx, &e.Post)
+
+	case events.PostDeleted:
+		app.publishPostDeleted(ctx, e.PostID, e.UserID)
+
+	case events.CommentCreated:
+		app.publishCommentCreated(ctx, &e.Comment, e.PostAuthorID)
+
+	case events.CommentUpdated:
+		if evt, ok := app.newStreamEvent(stream.PostTopic(e.Comment.PostID), stream.EventCommentUpdated, newCommentEvent(&e.Comment)); ok {
+			app.publish(ctx, evt)
+		}
+
+	case events.CommentDeleted:
+		data := map[string]int64{"id": e.CommentID, "post_id": e.PostID}
+		if evt, ok := app.newStreamEvent(stream.PostTopic(e.PostID), stream.EventCommentDeleted, data); ok {
+			app.publish(ctx, evt)
+		}
+	}
+	return nil
+}
+
+func (app *application) invitationEmailEventHandler(ctx context.Context, evt events.Event) error {
+	e, ok := evt.(events.InvitationCreated)
+	if !ok {
+		return nil
+	}
+	inv := e.Invitation
+
+	plainTextContent, htmlContent := templates.EmailInvitation(inv.Token)
+	if err := utils.SendEmail(
+		"Social Golang Company",
+		env.GetEnvOrPanic("COMPANY_EMAIL"), // must match SendGrid verified sender
+		"",
+		inv.Email,
+		"Invitation to Join Social 🎉",
+		plainTextContent,
+		htmlContent,
+	); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return app.store.Invitations.UpdateEmailStatus(ctx, inv.ID, &now)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+)
+
+// getExploreHandler serves the posts of every user, newest first, with cursor pagination (see
+// getUserFeedHandler), and the same expansions. Authors blocked or muted by the logged in user
+// are left out.
+func (app *application) getExploreHandler(w http.ResponseWriter, r *http.Request) {
+	app.explore(w, r, "")
+}
+
+// getTagPostsHandler serves the explore timeline of a single tag.
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := utils.NormalizeTag(chi.URLParam(r, "tag"))
+	if !ok {
+		app.badRequestError(w, r, errors.New("invalid tag"))
+		return
+	}
+
+	app.explore(w, r, tag)
+}
+
+func (app *application) explore(w http.ResponseWriter, r *http.Request, tag string) {
+	viewerID := middleware.GetAuthUserIDFromContext(r)
+
+	params := utils.ParseQueryParams(r)
+
+	// Invalid tags are ignored
+	tags, _ := utils.NormalizeTags(utils.ParseCSV(params["tags"]))
+
+	queryParams := dto.FeedQueryParams{
+		Limit:  utils.ParseIntWithDefaultAndMax(params["limit"], 25, 100),
+		Tags:   tags,
+		Search: params["search"],
+		Tag:    tag,
+	}
+
+	if err := parseFeedCursor(params["cursor"], &queryParams); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	includes, errMap := parseFeedIncludes(params)
+	if errMap != nil {
+		app.failedValidationError(w, r, errMap)
+		return
+	}
+
+	posts, hasMore, err := app.store.Posts.Explore(r.Context(), viewerID, queryParams)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.expandFeed(r.Context(), viewerID, posts, includes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	resp, err := newFeedCursorResponse(posts, hasMore, queryParams)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
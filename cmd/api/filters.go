@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+)
+
+// maxFilterRegexLength caps the regular expressions of word filters, which are matched against
+// every post of the feeds of the user.
+const maxFilterRegexLength = 100
+
+type createFilterPayload struct {
+	Kind      string `json:"kind" validate:"required,oneof=word tag language"`
+	Value     string `json:"value" validate:"required,max=255"`
+	WholeWord bool   `json:"whole_word"`
+	Regex     bool   `json:"regex"`
+	ExpiresIn int64  `json:"expires_in" validate:"gte=0"` // seconds, 0 for a filter that never expires
+}
+
+// createFilterHandler mutes a word or a tag, or adds a preferred language. Tags are normalized
+// like the tags of the posts. Creating a filter that already exists replaces its options and
+// expiry, e.g. to mute a word for 7 more days.
+func (app *application) createFilterHandler(w http.ResponseWriter, r *http.Request) {
+	var payload createFilterPayload
+
+	if err := utils.ReadJSON(r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		app.failedValidationError(w, r, err)
+		return
+	}
+
+	filter := &dto.ContentFilter{
+		UserID:    middleware.GetAuthUserIDFromContext(r),
+		Kind:      payload.Kind,
+		Value:     strings.TrimSpace(payload.Value),
+		WholeWord: payload.WholeWord,
+		IsRegex:   payload.Regex,
+	}
+
+	switch {
+	case filter.Value == "":
+		app.failedValidationError(w, r, map[string]string{"value": "value is required"})
+		return
+	case filter.Kind != dto.FilterKindWord && (filter.WholeWord || filter.IsRegex):
+		app.failedValidationError(w, r, map[string]string{"kind": "whole_word and regex only apply to word filters"})
+		return
+	case filter.WholeWord && filter.IsRegex:
+		app.failedValidationError(w, r, map[string]string{"whole_word": "whole_word cannot be combined with regex"})
+		return
+	case filter.IsRegex && len(filter.Value) > maxFilterRegexLength:
+		app.failedValidationError(w, r, map[string]string{"value": "regular expressions are at most 100 characters long"})
+		return
+	case filter.Kind == dto.FilterKindTag:
+		tag, ok := utils.NormalizeTag(filter.Value)
+		if !ok {
+			app.failedValidationError(w, r, map[string]string{"value": fmt.Sprintf("tags are 1 to %d characters long", utils.MaxTagLength)})
+			return
+		}
+		filter.Value = tag
+	case filter.Kind == dto.FilterKindLanguage:
+		filter.Value = strings.ToLower(filter.Value)
+		if len(filter.Value) != 2 || strings.Trim(filter.Value, "abcdefghijklmnopqrstuvwxyz") != "" {
+			app.failedValidationError(w, r, map[string]string{"value": "languages are ISO 639-1 codes, e.g. en"})
+			return
+		}
+	}
+
+	if payload.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(payload.ExpiresIn) * time.Second)
+		filter.ExpiresAt = &expiresAt
+	}
+
+	if err := app.store.Filters.Create(r.Context(), filter); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrInvalidPattern), errors.Is(err, errs.ErrComplexPattern):
+			app.failedValidationError(w, r, map[string]string{"value": err.Error()})
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusCreated, filter); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getFiltersHandler lists the filters of the logged in user that have not expired.
+func (app *application) getFiltersHandler(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	filters, err := app.store.Filters.GetActiveByUserID(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, filters); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) deleteFilterHandler(w http.ResponseWriter, r *http.Request) {
+	filterID, err := strconv.ParseInt(chi.URLParam(r, "filterID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, errors.New("invalid filter ID"))
+		return
+	}
+
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	if err := app.store.Filters.Delete(r.Context(), userID, filterID); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, map[string]string{"message": "Filter deleted successfully"}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
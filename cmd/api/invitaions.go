@@ -7,10 +7,9 @@ import (
 	"time"
 
 	"github.com/mafi020/social/internal/dto"
-	"github.com/mafi020/social/internal/env"
 	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/events"
 	"github.com/mafi020/social/internal/middleware"
-	"github.com/mafi020/social/internal/templates"
 	"github.com/mafi020/social/internal/utils"
 )
 
@@ -53,16 +52,6 @@ func (app *application) createInvitationHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	app.sendInvitationEmail(inv)
-
-	now := time.Now()
-	inv.EmailSentAt = &now
-
-	if err := app.store.Invitations.UpdateEmailStatus(ctx, inv.ID, &now); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
 	if err := utils.JSONResponse(w, http.StatusCreated, inv); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -84,11 +73,17 @@ func (app *application) createNewInvitation(ctx context.Context, inviterID int64
 		EmailSentAt: nil,
 	}
 
-	if err := app.store.Invitations.Create(ctx, inv); err != nil {
+	// The invitation email is sent by the invitation.created subscriber.
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := app.store.Invitations.Create(ctx, inv); err != nil {
+			return err
+		}
+		return app.events.Publish(ctx, events.InvitationCreated{Invitation: *inv})
+	}); err != nil {
 		return nil, err
 	}
 
-	return inv, err
+	return inv, nil
 }
 
 func (app *application) refreshAndResendInvitation(ctx context.Context, w http.ResponseWriter, r *http.Request, inv *dto.Invitation) error {
@@ -103,17 +98,12 @@ func (app *application) refreshAndResendInvitation(ctx context.Context, w http.R
 	inv.EmailSentAt = nil
 	inv.Status = "pending"
 
-	if err := app.store.Invitations.Update(ctx, inv); err != nil {
-		app.internalServerError(w, r, err)
-		return nil
-	}
-
-	app.sendInvitationEmail(inv)
-
-	now := time.Now()
-	inv.EmailSentAt = &now
-
-	if err := app.store.Invitations.UpdateEmailStatus(ctx, inv.ID, &now); err != nil {
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := app.store.Invitations.Update(ctx, inv); err != nil {
+			return err
+		}
+		return app.events.Publish(ctx, events.InvitationCreated{Invitation: *inv})
+	}); err != nil {
 		app.internalServerError(w, r, err)
 		return nil
 	}
@@ -156,23 +146,6 @@ func (app *application) handleExistingInvitation(ctx context.Context, w http.Res
 	return false
 }
 
-func (app *application) sendInvitationEmail(inv *dto.Invitation) {
-	plainTextContent, htmlContent := templates.EmailInvitation(inv.Token)
-	go func() {
-		if err := utils.SendEmail(
-			"Social Golang Company",
-			env.GetEnvOrPanic("COMPANY_EMAIL"), // must match SendGrid verified sender
-			"",
-			inv.Email,
-			"Invitation to Join Social 🎉",
-			plainTextContent,
-			htmlContent,
-		); err != nil {
-			app.logger.Error("failed to send invitation email", "error", err)
-		}
-	}()
-}
-
 func (app *application) acceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("token")
 	if token == "" {
@@ -198,7 +171,13 @@ func (app *application) acceptInvitationHandler(w http.ResponseWriter, r *http.R
 
 	// At this point, you could prompt the invited user to set a password & register
 	// Once registered, update status:
-	if err := app.store.Invitations.UpdateStatus(r.Context(), inv.ID, "accepted"); err != nil {
+	if err := app.store.WithTx(r.Context(), func(ctx context.Context) error {
+		if err := app.store.Invitations.UpdateStatus(ctx, inv.ID, "accepted"); err != nil {
+			return err
+		}
+		inv.Status = "accepted"
+		return app.events.Publish(ctx, events.InvitationAccepted{Invitation: *inv})
+	}); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -1,15 +1,33 @@
 package main
 
 import (
+	"context"
+	"strings"
+	"time"
+
 	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
 	"github.com/mafi020/social/internal/env"
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/interfaces"
 	log "github.com/mafi020/social/internal/logger"
+	"github.com/mafi020/social/internal/media"
+	"github.com/mafi020/social/internal/scheduler"
+	"github.com/mafi020/social/internal/search"
 	"github.com/mafi020/social/internal/store"
+	"github.com/mafi020/social/internal/stream"
+	"github.com/mafi020/social/internal/trash"
+	"github.com/mafi020/social/internal/trending"
+	"github.com/mafi020/social/internal/utils"
+	"github.com/mafi020/social/internal/webhooks"
 )
 
 func main() {
+	port := env.GetEnvOrPanic("PORT")
+
 	cfg := &config{
-		port: env.GetEnvOrPanic("PORT"),
+		port:    port,
+		baseURL: strings.TrimSuffix(env.GetEnvOrDefault("BASE_URL", "http://localhost"+port), "/"),
 		db: &dbConfig{
 			url:          env.GetEnvOrPanic("PSQL_URL"),
 			maxOpenConns: env.GetEnvAsIntOrPanic("PSQL_MAX_OPEN_CONNS"),
@@ -17,6 +35,72 @@ func main() {
 			maxIdleTime:  env.GetEnvOrPanic("PSQL_MAX_IDLE_TIME"),
 		},
 		env: env.GetEnvOrPanic("ENVIRONMENT"),
+		stream: &streamConfig{
+			pgNotify: env.GetEnvAsBoolOrDefault("STREAM_PG_NOTIFY", false),
+			channel:  env.GetEnvOrDefault("STREAM_PG_CHANNEL", "social_events"),
+		},
+		webhooks: &webhooksConfig{
+			workerEnabled:        env.GetEnvAsBoolOrDefault("WEBHOOKS_WORKER_ENABLED", true),
+			maxAttempts:          env.GetEnvAsIntOrDefault("WEBHOOKS_MAX_ATTEMPTS", 8),
+			allowPrivateNetworks: env.GetEnvAsBoolOrDefault("WEBHOOKS_ALLOW_PRIVATE_NETWORKS", false),
+		},
+		events: &eventsConfig{
+			outboxEnabled: env.GetEnvAsBoolOrDefault("EVENTS_OUTBOX_ENABLED", false),
+			maxAttempts:   env.GetEnvAsIntOrDefault("EVENTS_MAX_ATTEMPTS", 5),
+		},
+		timelines: &timelinesConfig{
+			fanOutMaxFollowers: env.GetEnvAsIntOrDefault("TIMELINES_FANOUT_MAX_FOLLOWERS", 10000),
+			backfillLimit:      env.GetEnvAsIntOrDefault("TIMELINES_BACKFILL_LIMIT", 200),
+		},
+		feedRanking: &dto.FeedRanking{
+			RecencyWeight:   env.GetEnvAsFloatOrDefault("FEED_TOP_RECENCY_WEIGHT", 3),
+			CommentsWeight:  env.GetEnvAsFloatOrDefault("FEED_TOP_COMMENTS_WEIGHT", 1),
+			ReactionsWeight: env.GetEnvAsFloatOrDefault("FEED_TOP_REACTIONS_WEIGHT", 0.5),
+			AffinityWeight:  env.GetEnvAsFloatOrDefault("FEED_TOP_AFFINITY_WEIGHT", 1),
+			HalfLifeHours:   env.GetEnvAsFloatOrDefault("FEED_TOP_HALF_LIFE_HOURS", 12),
+			WindowHours:     env.GetEnvAsIntOrDefault("FEED_TOP_WINDOW_HOURS", 72),
+		},
+		trending: &trending.Config{
+			RefreshInterval: time.Duration(env.GetEnvAsIntOrDefault("TRENDING_REFRESH_SECONDS", 300)) * time.Second,
+			MinPosts:        env.GetEnvAsIntOrDefault("TRENDING_MIN_POSTS", 3),
+			Limit:           50,
+		},
+		search: &searchConfig{
+			backend:   env.GetEnvOrDefault("SEARCH_BACKEND", search.BackendPostgres),
+			blevePath: env.GetEnvOrDefault("SEARCH_BLEVE_PATH", "data/search.bleve"),
+		},
+		syndication: &syndicationConfig{
+			limit: env.GetEnvAsIntOrDefault("SYNDICATION_FEED_LIMIT", 20),
+		},
+		scheduler: &scheduler.Config{
+			PollInterval: time.Duration(env.GetEnvAsIntOrDefault("SCHEDULER_POLL_SECONDS", 30)) * time.Second,
+			BatchSize:    50,
+		},
+		trash: &trash.Config{
+			Retention:    time.Duration(env.GetEnvAsIntOrDefault("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour,
+			PollInterval: time.Hour,
+			BatchSize:    500,
+		},
+		media: &mediaConfig{
+			backend:        env.GetEnvOrDefault("MEDIA_BACKEND", media.BackendLocal),
+			localPath:      env.GetEnvOrDefault("MEDIA_LOCAL_PATH", "data/media"),
+			maxAttachments: 4,
+			urlSecret:      env.GetEnvOrDefault("MEDIA_URL_SECRET", env.GetEnvOrPanic("JWT_SECRET")),
+			urlTTL:         time.Duration(env.GetEnvAsIntOrDefault("MEDIA_URL_TTL_MINUTES", 60)) * time.Minute,
+			processing: media.Config{
+				MaxSize:       int64(env.GetEnvAsIntOrDefault("MEDIA_MAX_UPLOAD_MB", 10)) << 20,
+				MaxPixels:     4096 * 4096,
+				ThumbnailSize: 400,
+			},
+			cleaner: media.CleanerConfig{
+				MaxAge:       24 * time.Hour,
+				PollInterval: time.Hour,
+				BatchSize:    100,
+			},
+		},
+		reactions: &reactionsConfig{
+			types: utils.ParseCSV(env.GetEnvOrDefault("REACTION_TYPES", "like,love,laugh,wow,sad,angry")),
+		},
 	}
 
 	// Logger: https://github.com/uber-go/zap
@@ -33,11 +117,108 @@ func main() {
 
 	store := store.NewPostgresStorage(db)
 
+	// Real-time pub/sub: in-process, optionally fanned out to every replica via LISTEN/NOTIFY
+	hub := stream.NewHub(64)
+	if cfg.stream.pgNotify {
+		broker, err := stream.NewPostgresBroker(db, cfg.db.url, cfg.stream.channel, logger)
+		if err != nil {
+			logger.Panicw("Failed to listen for stream events", "error", err)
+		}
+		hub.UseBroker(broker)
+		go broker.Run(hub)
+		logger.Infow("Stream events fanned out through Postgres", "channel", cfg.stream.channel)
+	}
+	defer hub.Close()
+
+	// Background jobs stop when the server has shut down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if cfg.webhooks.workerEnabled {
+		worker := webhooks.NewWorker(store.Webhooks, logger, webhooks.WorkerConfig{
+			PollInterval:         5 * time.Second,
+			BatchSize:            20,
+			MaxAttempts:          cfg.webhooks.maxAttempts,
+			BaseBackoff:          30 * time.Second,
+			MaxBackoff:           6 * time.Hour,
+			Timeout:              10 * time.Second,
+			AllowPrivateNetworks: cfg.webhooks.allowPrivateNetworks,
+		})
+		go worker.Run(ctx)
+	}
+
+	// Domain events: dispatched after commit, or through the transactional outbox
+	bus := events.NewBus(logger)
+	if cfg.events.outboxEnabled {
+		bus.UseOutbox(store.Outbox)
+		relay := events.NewRelay(store.Outbox, bus, logger, 2*time.Second, 50, cfg.events.maxAttempts)
+		go relay.Run(ctx)
+		logger.Infow("Domain events dispatched through the outbox")
+	}
+	defer bus.Wait()
+
+	// Trending tags are computed in the background and served from memory
+	trendingTags := trending.NewService(store.Tags, logger, *cfg.trending)
+	go trendingTags.Run(ctx)
+
+	// Search: Postgres full-text search, or an embedded Bleve index fed by the domain events
+	var searchIndex interfaces.SearchIndex = search.NewPostgresIndex(store.Search)
+	if cfg.search.backend == search.BackendBleve {
+		index, err := search.OpenBleve(cfg.search.blevePath)
+		if err != nil {
+			logger.Panicw("Failed to open the search index", "path", cfg.search.blevePath, "error", err)
+		}
+		bleveIndex := search.NewBleveIndex(index, store.Posts, store.Comments, store.Blocks, store.Filters, searchIndex)
+		defer bleveIndex.Close()
+		searchIndex = bleveIndex
+		logger.Infow("Search index opened", "path", cfg.search.blevePath)
+	}
+
+	// Media: uploads kept on the local disk and served by the API, or in an S3-compatible bucket
+	mediaURLs := media.NewURLSigner(cfg.media.urlSecret, cfg.media.urlTTL)
+	var blobs interfaces.BlobStore
+	switch cfg.media.backend {
+	case media.BackendS3:
+		blobs, err = media.NewS3Store(media.S3Config{
+			Endpoint:        env.GetEnvOrPanic("S3_ENDPOINT"),
+			Region:          env.GetEnvOrDefault("S3_REGION", "us-east-1"),
+			Bucket:          env.GetEnvOrPanic("S3_BUCKET"),
+			AccessKeyID:     env.GetEnvOrPanic("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: env.GetEnvOrPanic("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       env.GetEnvOrDefault("S3_PUBLIC_URL", ""),
+		})
+	default:
+		blobs, err = media.NewLocalStore(cfg.media.localPath, cfg.baseURL+"/api/media/files", mediaURLs)
+	}
+	if err != nil {
+		logger.Panicw("Failed to set up the media storage", "backend", cfg.media.backend, "error", err)
+	}
+
+	cleaner := media.NewCleaner(store.Media, blobs, logger, cfg.media.cleaner)
+	go cleaner.Run(ctx)
+
 	app := &application{
-		config: cfg,
-		store:  store,
-		logger: logger,
+		config:    cfg,
+		store:     store,
+		logger:    logger,
+		hub:       hub,
+		events:    bus,
+		trending:  trendingTags,
+		search:    searchIndex,
+		blobs:     blobs,
+		mediaURLs: mediaURLs,
 	}
+	app.registerEventHandlers()
+
+	// Scheduled posts are published in the background, once the event handlers are registered
+	publisher := scheduler.NewPublisher(store.Posts, store.WithTx, bus, logger, *cfg.scheduler)
+	go publisher.Run(ctx)
 
-	logger.Fatal(app.start(app.mount()))
+	// Deleted posts and comments are purged once their retention period is over
+	purger := trash.NewPurger(store.Posts, store.Comments, logger, *cfg.trash)
+	go purger.Run(ctx)
+
+	if err := app.start(app.mount()); err != nil {
+		logger.Fatal(err)
+	}
 }
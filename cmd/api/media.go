@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/media"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+)
+
+// uploadMediaHandler stores an uploaded image (multipart field "file", with an optional "alt"
+// text) along with its thumbnail. The returned media is attached to a post by passing its ID
+// in media_ids when creating or updating the post.
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := app.config.media.processing
+	tooLarge := map[string]string{"file": fmt.Sprintf("files are at most %d MB", cfg.MaxSize>>20)}
+
+	// The limit leaves room for the multipart framing and the alt text
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxSize+1<<20)
+	if err := r.ParseMultipartForm(cfg.MaxSize); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			app.failedValidationError(w, r, tooLarge)
+			return
+		}
+		app.badRequestError(w, r, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.failedValidationError(w, r, map[string]string{"file": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > cfg.MaxSize {
+		app.failedValidationError(w, r, tooLarge)
+		return
+	}
+
+	alt := strings.TrimSpace(r.FormValue("alt"))
+	if len(alt) > 1000 {
+		app.failedValidationError(w, r, map[string]string{"alt": "alt is at most 1000 characters long"})
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	processed, err := media.Process(data, cfg)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			app.failedValidationError(w, r, map[string]string{"file": "only JPEG, PNG and GIF images are supported"})
+		case errors.Is(err, media.ErrTooManyPixels):
+			app.failedValidationError(w, r, map[string]string{"file": err.Error()})
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	key, thumbnailKey, err := media.NewKeys(processed.Extension)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.blobs.Put(ctx, key, processed.MimeType, data); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.blobs.Put(ctx, thumbnailKey, "image/jpeg", processed.Thumbnail); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	m := &dto.Media{
+		UserID:       middleware.GetAuthUserIDFromContext(r),
+		StorageKey:   key,
+		ThumbnailKey: thumbnailKey,
+		MimeType:     processed.MimeType,
+		Size:         int64(len(data)),
+		Width:        processed.Width,
+		Height:       processed.Height,
+		AltText:      alt,
+	}
+
+	// Blobs stored without their row are orphans nothing removes, clean them up right away
+	if err := app.store.Media.Create(ctx, m); err != nil {
+		app.blobs.Delete(context.WithoutCancel(ctx), key)
+		app.blobs.Delete(context.WithoutCancel(ctx), thumbnailKey)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setMediaURLs(m)
+
+	if err := utils.JSONResponse(w, http.StatusCreated, m); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getMediaFileHandler serves the blobs of the local media storage, at the signed URLs handed
+// out with the posts the viewer may read (see media.URLSigner).
+func (app *application) getMediaFileHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+
+	if err := app.mediaURLs.Verify(key, r.URL.Query(), time.Now()); err != nil {
+		app.forbiddenError(w, r, err)
+		return
+	}
+
+	blob, err := app.blobs.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer blob.Close()
+
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Keys are never reused, the files never change, but the URLs expire
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d, immutable", int(app.config.media.urlTTL.Seconds())))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// loadAttachments sets the attachments of the posts, with a single query.
+func (app *application) loadAttachments(ctx context.Context, posts ...*dto.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	postIDs := make([]int64, len(posts))
+	for i, p := range posts {
+		postIDs[i] = p.ID
+	}
+
+	attachments, err := app.store.Media.GetByPostIDs(ctx, postIDs)
+	if err != nil {
+		return err
+	}
+
+	byPost := make(map[int64][]dto.Media, len(posts))
+	for _, m := range attachments {
+		app.setMediaURLs(&m)
+		byPost[*m.PostID] = append(byPost[*m.PostID], m)
+	}
+	for _, p := range posts {
+		p.Attachments = byPost[p.ID]
+	}
+
+	return nil
+}
+
+func (app *application) setMediaURLs(m *dto.Media) {
+	m.URL = app.blobs.URL(m.StorageKey)
+	m.ThumbnailURL = app.blobs.URL(m.ThumbnailKey)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/markdown"
+)
+
+// maxMentions caps the users mentioned by a post or comment, the mentions past it are ignored.
+const maxMentions = 20
+
+// saveMentions resolves the users mentioned in the content and records them as the mentions of
+// the post, or of its comment when commentID is set. It returns the mentions, and the users
+// that were not mentioned before.
+func (app *application) saveMentions(ctx context.Context, postID int64, commentID *int64, content string) ([]dto.Mention, []int64, error) {
+	usernames := markdown.Mentions(content)
+	if len(usernames) > maxMentions {
+		usernames = usernames[:maxMentions]
+	}
+
+	mentions := []dto.Mention{}
+	if len(usernames) > 0 {
+		users, err := app.store.Users.GetByUsernames(ctx, usernames)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		byUsername := make(map[string]dto.User, len(users))
+		for _, u := range users {
+			byUsername[u.UserName] = u
+		}
+		for _, username := range usernames {
+			if u, ok := byUsername[username]; ok {
+				mentions = append(mentions, dto.Mention{UserID: u.ID, UserName: u.UserName, PostID: postID, CommentID: commentID})
+			}
+		}
+	}
+
+	userIDs := make([]int64, len(mentions))
+	for i, m := range mentions {
+		userIDs[i] = m.UserID
+	}
+
+	added, err := app.store.Mentions.Set(ctx, postID, commentID, userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mentions, added, nil
+}
+
+// loadMentions sets the mentions of the posts, with a single query.
+func (app *application) loadMentions(ctx context.Context, posts ...*dto.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	postIDs := make([]int64, len(posts))
+	for i, p := range posts {
+		postIDs[i] = p.ID
+	}
+
+	mentions, err := app.store.Mentions.GetByPostIDs(ctx, postIDs)
+	if err != nil {
+		return err
+	}
+
+	byPost := make(map[int64][]dto.Mention, len(posts))
+	for _, m := range mentions {
+		byPost[m.PostID] = append(byPost[m.PostID], m)
+	}
+	for _, p := range posts {
+		p.Mentions = byPost[p.ID]
+	}
+
+	return nil
+}
+
+// loadCommentMentions sets the mentions of the comments, with a single query.
+func (app *application) loadCommentMentions(ctx context.Context, comments ...*dto.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	commentIDs := make([]int64, len(comments))
+	for i, c := range comments {
+		commentIDs[i] = c.ID
+	}
+
+	mentions, err := app.store.Mentions.GetByCommentIDs(ctx, commentIDs)
+	if err != nil {
+		return err
+	}
+
+	byComment := make(map[int64][]dto.Mention, len(comments))
+	for _, m := range mentions {
+		byComment[*m.CommentID] = append(byComment[*m.CommentID], m)
+	}
+	for _, c := range comments {
+		c.Mentions = byComment[c.ID]
+	}
+
+	return nil
+}
+
+// notifyMentions notifies the users mentioned by the actor in the post, or in its comment when
+// commentID is set. Users who may not read the post, or block or mute the actor, are left out.
+func (app *application) notifyMentions(ctx context.Context, actorID, postID int64, commentID *int64, userIDs []int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	notifiable, err := app.store.Mentions.GetNotifiable(ctx, actorID, postID, userIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range notifiable {
+		if err := app.notify(ctx, &dto.Notification{
+			UserID:    userID,
+			ActorID:   &actorID,
+			Type:      dto.NotificationMention,
+			PostID:    &postID,
+			CommentID: commentID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+func TestCreatePostNotifiesMentions(t *testing.T) {
+	app, conn := newTestApplication(t)
+
+	author := createTestUser(t, app, "author")
+	mentioned := createTestUser(t, app, "mentioned")
+
+	w := serve(t, app.createPostHandler, http.MethodPost, createPostPayload{
+		Title:   "Mentions",
+		Content: "Hello @" + mentioned.UserName + ", and @" + author.UserName,
+		UserID:  author.ID,
+	}, author.ID, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create post: got status %d: %s", w.Code, w.Body)
+	}
+	var post dto.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &post); err != nil {
+		t.Fatal(err)
+	}
+	if len(post.Mentions) != 2 {
+		t.Fatalf("got mentions %v, want both users", post.Mentions)
+	}
+
+	countMentions := func(userID int64) int {
+		var count int
+		err := conn.QueryRow(
+			`SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND type = $2 AND post_id = $3 AND actor_id = $4`,
+			userID, dto.NotificationMention, post.ID, author.ID,
+		).Scan(&count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	if got := countMentions(mentioned.ID); got != 1 {
+		t.Errorf("got %d mention notifications for the mentioned user, want 1", got)
+	}
+	if got := countMentions(author.ID); got != 0 {
+		t.Errorf("got %d mention notifications for the author mentioning themselves, want 0", got)
+	}
+}
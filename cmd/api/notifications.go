@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+)
+
+type notificationsResponse struct {
+	Notifications []dto.Notification `json:"notifications"`
+	NextCursor    *string            `json:"next_cursor"`
+}
+
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	params := utils.ParseQueryParams(r)
+
+	var cursor int64
+	if params["cursor"] != "" {
+		c, err := strconv.ParseInt(params["cursor"], 10, 64)
+		if err != nil || c < 1 {
+			app.badRequestError(w, r, errors.New("invalid cursor"))
+			return
+		}
+		cursor = c
+	}
+
+	queryParams := dto.NotificationQueryParams{
+		Limit:      utils.ParseIntWithDefaultAndMax(params["limit"], 25, 100),
+		Cursor:     cursor,
+		UnreadOnly: params["unread"] == "true",
+	}
+
+	notifications, err := app.store.Notifications.GetByUserID(r.Context(), userID, queryParams)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	resp := notificationsResponse{Notifications: notifications}
+	if len(notifications) == queryParams.Limit {
+		next := strconv.FormatInt(notifications[len(notifications)-1].ID, 10)
+		resp.NextCursor = &next
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) getUnreadNotificationsCountHandler(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	count, err := app.store.Notifications.UnreadCount(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, map[string]int{"unread_count": count}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	paramNotificationID := chi.URLParam(r, "notificationID")
+	notificationID, err := strconv.ParseInt(paramNotificationID, 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, errors.New("invalid notification ID"))
+		return
+	}
+
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	if err := app.store.Notifications.MarkRead(r.Context(), userID, notificationID); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, map[string]string{"message": "Notification marked as read"}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	updated, err := app.store.Notifications.MarkAllRead(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, map[string]int64{"updated": updated}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// notify stores a notification for the recipient and pushes it to their open streams.
+// Users are not notified of their own actions.
+func (app *application) notify(ctx context.Context, n *dto.Notification) error {
+	if n.ActorID != nil && *n.ActorID == n.UserID {
+		return nil
+	}
+	if err := app.store.Notifications.Create(ctx, n); err != nil {
+		return err
+	}
+	app.publishNotification(ctx, n)
+	return nil
+}
@@ -1,24 +1,39 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/mafi020/social/internal/dto"
 	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/markdown"
+	"github.com/mafi020/social/internal/middleware"
 	"github.com/mafi020/social/internal/utils"
 )
 
 type createPostPayload struct {
-	Title   string   `json:"title" validate:"required"`
-	Content string   `json:"content" validate:"required"`
-	UserID  int64    `json:"user_id" validate:"required"`
-	Tags    []string `json:"tags" validate:"required"`
+	Title      string     `json:"title" validate:"required"`
+	Content    string     `json:"content" validate:"required"`
+	UserID     int64      `json:"user_id" validate:"required"`
+	Tags       []string   `json:"tags"`                                                // merged with the #hashtags of the content
+	Language   string     `json:"language" validate:"omitempty,len=2,alpha,lowercase"` // ISO 639-1
+	Visibility string     `json:"visibility" validate:"omitempty,oneof=public unlisted followers private"`
+	Status     string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt  *time.Time `json:"publish_at"`                            // required for scheduled posts
+	MediaIDs   []int64    `json:"media_ids" validate:"omitempty,unique"` // uploaded media to attach, in order
+	QuoteOfID  *int64     `json:"quote_of_id"`                           // post to quote
 }
 
+// createPostHandler publishes the post, or saves it as a draft or scheduled post. The side
+// effects of a new post (fan-out, notifications, webhooks...) happen when it is published.
 func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request) {
 	var payload createPostPayload
 
@@ -32,17 +47,84 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	tags, errMap := postTags(payload.Tags, payload.Content)
+	if errMap != nil {
+		app.failedValidationError(w, r, errMap)
+		return
+	}
+
 	ctx := r.Context()
 
 	post := dto.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  payload.UserID,
+		Title:       payload.Title,
+		Content:     payload.Content,
+		ContentHTML: markdown.Render(payload.Content),
+		Tags:        tags,
+		UserID:      payload.UserID,
+		Language:    payload.Language,
+		Visibility:  payload.Visibility,
+	}
+	if post.Visibility == "" {
+		post.Visibility = dto.VisibilityPublic
 	}
 
-	if err := app.store.Posts.Create(ctx, &post); err != nil {
-		app.internalServerError(w, r, err)
+	status := payload.Status
+	if status == "" {
+		status = dto.PostStatusPublished
+	}
+	if errMap := setPostStatus(&post, status, payload.PublishAt); errMap != nil {
+		app.failedValidationError(w, r, errMap)
+		return
+	}
+	if errMap := app.validateMediaIDs(payload.MediaIDs); errMap != nil {
+		app.failedValidationError(w, r, errMap)
+		return
+	}
+	if payload.QuoteOfID != nil {
+		quoted, errMap, err := app.quotedPost(ctx, *payload.QuoteOfID, payload.UserID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if errMap != nil {
+			app.failedValidationError(w, r, errMap)
+			return
+		}
+		post.QuoteOfID = &quoted.ID
+		post.QuoteOf = quoted
+	}
+
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := app.store.Posts.Create(ctx, &post); err != nil {
+			return err
+		}
+		if err := app.store.Revisions.Create(ctx, post.ID, post.UserID); err != nil {
+			return err
+		}
+		mentions, _, err := app.saveMentions(ctx, post.ID, nil, post.Content)
+		if err != nil {
+			return err
+		}
+		post.Mentions = mentions
+		if len(payload.MediaIDs) > 0 {
+			if err := app.store.Media.Attach(ctx, post.ID, post.UserID, payload.MediaIDs); err != nil {
+				return err
+			}
+			if err := app.loadAttachments(ctx, &post); err != nil {
+				return err
+			}
+		}
+		if post.Status != dto.PostStatusPublished {
+			return nil
+		}
+		return app.events.Publish(ctx, events.PostCreated{Post: post})
+	}); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrInvalidMedia):
+			app.failedValidationError(w, r, map[string]string{"media_ids": err.Error()})
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
@@ -66,11 +148,13 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	post, err := app.store.Posts.GetByID(ctx, postID)
+	post, err := app.store.Posts.GetByID(ctx, postID, middleware.GetAuthUserIDFromContext(r))
 	if err != nil {
 		switch {
 		case errors.Is(err, errs.ErrNotFound):
 			app.notFoundError(w, r, err)
+		case errors.Is(err, errs.ErrForbidden):
+			app.forbiddenError(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
@@ -90,6 +174,39 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	post.Comments = comments
 
+	viewerID := middleware.GetAuthUserIDFromContext(r)
+	if err := app.store.Posts.EmbedOriginals(ctx, viewerID, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	posts := withOriginals(post)
+	if err := app.loadAttachments(ctx, posts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.loadMentions(ctx, posts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	commentPtrs := make([]*dto.Comment, len(post.Comments))
+	for i := range post.Comments {
+		commentPtrs[i] = &post.Comments[i]
+	}
+	if err := app.loadCommentMentions(ctx, commentPtrs...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.loadReactions(ctx, viewerID, posts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.loadCommentReactions(ctx, viewerID, commentPtrs...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := utils.JSONResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -105,7 +222,13 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
-	if err := app.store.Posts.Delete(ctx, postId); err != nil {
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		userID, err := app.store.Posts.Delete(ctx, postId)
+		if err != nil {
+			return err
+		}
+		return app.events.Publish(ctx, events.PostDeleted{PostID: postId, UserID: userID})
+	}); err != nil {
 		switch {
 		case errors.Is(err, errs.ErrNotFound):
 			app.notFoundError(w, r, err)
@@ -123,9 +246,14 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 // Important to use pointer to distinguish between intentional empty fields and struct generated nil fields if not provided
 type updatePostPayload struct {
 	// Ttile type as pointer string means, it'a an optional field
-	Title   *string   `json:"title" validate:"omitempty,min=1"`
-	Content *string   `json:"content" validate:"omitempty,min=1"`
-	Tags    *[]string `json:"tags" validate:"omitempty,min=1"`
+	Title      *string    `json:"title" validate:"omitempty,min=1"`
+	Content    *string    `json:"content" validate:"omitempty,min=1"`
+	Tags       *[]string  `json:"tags"`                                                // replaces the tags not coming from the #hashtags of the content
+	Language   *string    `json:"language" validate:"omitempty,len=2,alpha,lowercase"` // an empty language clears it
+	Visibility *string    `json:"visibility" validate:"omitempty,oneof=public unlisted followers private"`
+	Status     *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt  *time.Time `json:"publish_at"`
+	MediaIDs   *[]int64   `json:"media_ids" validate:"omitempty,unique"` // replaces the attachments, empty to remove them
 }
 
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
@@ -151,28 +279,138 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 
 	ctx := r.Context()
 
-	post, err := app.store.Posts.GetByID(ctx, postID)
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	post, err := app.store.Posts.GetByID(ctx, postID, userID)
 	if err != nil {
-		if errors.Is(err, errs.ErrNotFound) {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
 			app.notFoundError(w, r, err)
-		} else {
+		case errors.Is(err, errs.ErrForbidden):
+			app.forbiddenError(w, r, err)
+		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
+	if post.RepostOfID != nil {
+		app.badRequestError(w, r, errors.New("reposts cannot be edited"))
+		return
+	}
+
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
+	if payload.Content != nil || payload.Tags != nil {
+		var explicit []string
+		if payload.Tags != nil {
+			explicit = *payload.Tags
+		} else {
+			// The post keeps the tags that don't come from the hashtags of its previous content
+			hashtags, _ := utils.NormalizeTags(markdown.Hashtags(post.Content))
+			for _, tag := range post.Tags {
+				if !slices.Contains(hashtags, tag) {
+					explicit = append(explicit, tag)
+				}
+			}
+		}
+
+		content := post.Content
+		if payload.Content != nil {
+			content = *payload.Content
+		}
+
+		tags, errMap := postTags(explicit, content)
+		if errMap != nil {
+			app.failedValidationError(w, r, errMap)
+			return
+		}
+		post.Tags = tags
+	}
 	if payload.Content != nil {
 		post.Content = *payload.Content
+		post.ContentHTML = markdown.Render(post.Content)
 	}
-	if payload.Tags != nil {
-		post.Tags = *payload.Tags
+	if payload.Language != nil {
+		post.Language = *payload.Language
+	}
+	if payload.Visibility != nil && *payload.Visibility != "" {
+		if post.UserID != userID {
+			app.forbiddenError(w, r, errors.New("only the author can change the visibility of a post"))
+			return
+		}
+		post.Visibility = *payload.Visibility
 	}
 
-	if err := app.store.Posts.Update(ctx, post); err != nil {
-		app.internalServerError(w, r, err)
+	wasPublished := post.Status == dto.PostStatusPublished
+	if payload.Status != nil || payload.PublishAt != nil {
+		if post.UserID != userID {
+			app.forbiddenError(w, r, errors.New("only the author can publish or schedule a post"))
+			return
+		}
+
+		status := post.Status
+		if payload.Status != nil && *payload.Status != "" {
+			status = *payload.Status
+		}
+		if errMap := setPostStatus(post, status, payload.PublishAt); errMap != nil {
+			app.failedValidationError(w, r, errMap)
+			return
+		}
+	}
+
+	if payload.MediaIDs != nil {
+		if post.UserID != userID {
+			app.forbiddenError(w, r, errors.New("only the author can change the attachments of a post"))
+			return
+		}
+		if errMap := app.validateMediaIDs(*payload.MediaIDs); errMap != nil {
+			app.failedValidationError(w, r, errMap)
+			return
+		}
+	}
+
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := app.store.Posts.Update(ctx, post); err != nil {
+			return err
+		}
+		if err := app.store.Revisions.Create(ctx, post.ID, userID); err != nil {
+			return err
+		}
+		mentions, added, err := app.saveMentions(ctx, post.ID, nil, post.Content)
+		if err != nil {
+			return err
+		}
+		post.Mentions = mentions
+		if payload.MediaIDs != nil {
+			if err := app.store.Media.Attach(ctx, post.ID, userID, *payload.MediaIDs); err != nil {
+				return err
+			}
+		}
+		if err := app.loadAttachments(ctx, post); err != nil {
+			return err
+		}
+		switch {
+		case post.Status != dto.PostStatusPublished:
+			return nil
+		case !wasPublished:
+			return app.events.Publish(ctx, events.PostCreated{Post: *post})
+		default:
+			if len(added) > 0 {
+				if err := app.events.Publish(ctx, events.UsersMentioned{ActorID: userID, PostID: post.ID, UserIDs: added}); err != nil {
+					return err
+				}
+			}
+			return app.events.Publish(ctx, events.PostUpdated{Post: *post})
+		}
+	}); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrInvalidMedia):
+			app.failedValidationError(w, r, map[string]string{"media_ids": err.Error()})
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
@@ -183,3 +421,87 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 }
+
+// postTags merges the tags with the hashtags of the content, normalized (see
+// utils.NormalizeTag). Hashtags too long to be tags are ignored, explicit tags are validated.
+func postTags(tags []string, content string) ([]string, map[string]string) {
+	explicit, ok := utils.NormalizeTags(tags)
+	if !ok {
+		return nil, map[string]string{"tags": fmt.Sprintf("tags are 1 to %d characters long", utils.MaxTagLength)}
+	}
+
+	hashtags, _ := utils.NormalizeTags(markdown.Hashtags(content))
+	merged, _ := utils.NormalizeTags(explicit, hashtags)
+	return merged, nil
+}
+
+// setPostStatus moves the post to the status. Published posts cannot go back to drafts, and
+// scheduled posts need a publication time in the future.
+func setPostStatus(post *dto.Post, status string, publishAt *time.Time) map[string]string {
+	if post.Status == dto.PostStatusPublished {
+		if status != dto.PostStatusPublished {
+			return map[string]string{"status": "published posts cannot be unpublished"}
+		}
+		return nil
+	}
+
+	switch status {
+	case dto.PostStatusScheduled:
+		if publishAt == nil {
+			publishAt = post.PublishAt
+		}
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return map[string]string{"publish_at": "scheduled posts need a publish_at in the future"}
+		}
+		post.PublishAt = publishAt
+	default:
+		post.PublishAt = nil
+	}
+
+	post.Status = status
+	return nil
+}
+
+func (app *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	posts, err := app.store.Posts.GetUnpublishedByUserID(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range posts {
+		posts[i].Comments = []dto.Comment{}
+	}
+
+	drafts := make([]*dto.Post, len(posts))
+	for i := range posts {
+		drafts[i] = &posts[i]
+	}
+	if err := app.store.Posts.EmbedOriginals(r.Context(), userID, drafts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	drafts = withOriginals(drafts...)
+	if err := app.loadAttachments(r.Context(), drafts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.loadMentions(r.Context(), drafts...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) validateMediaIDs(ids []int64) map[string]string {
+	if limit := app.config.media.maxAttachments; len(ids) > limit {
+		return map[string]string{"media_ids": fmt.Sprintf("posts have at most %d attachments", limit)}
+	}
+	return nil
+}
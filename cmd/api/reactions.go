@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+)
+
+type reactionPayload struct {
+	Type string `json:"type" validate:"required"`
+}
+
+type reactionsResponse struct {
+	Reactions  []dto.Reaction `json:"reactions"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+// readableCommentFromRoute returns the comment of the route if the user may read its post.
+// Otherwise the error response is written and ok is false.
+func (app *application) readableCommentFromRoute(w http.ResponseWriter, r *http.Request) (comment *dto.Comment, ok bool) {
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, errors.New("invalid comment ID"))
+		return nil, false
+	}
+
+	ctx := r.Context()
+
+	comment, err = app.store.Comments.GetByID(ctx, commentID)
+	if err == nil {
+		_, err = app.store.Posts.GetByID(ctx, comment.PostID, middleware.GetAuthUserIDFromContext(r))
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, errs.ErrForbidden):
+			app.forbiddenError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return comment, true
+}
+
+// reactToPostHandler sets the reaction of the user to the post, replacing their previous one.
+// Like comments, reactions go to the original of a repost.
+func (app *application) reactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.readablePostFromRoute(w, r)
+	if !ok {
+		return
+	}
+	if post.RepostOfID != nil {
+		app.badRequestError(w, r, errors.New("reposts cannot be reacted to, react to the original post"))
+		return
+	}
+
+	app.react(w, r, dto.ReactionTargetPost, post.ID)
+}
+
+func (app *application) deletePostReactionHandler(w http.ResponseWriter, r *http.Request) {
+	if post, ok := app.readablePostFromRoute(w, r); ok {
+		app.deleteReaction(w, r, dto.ReactionTargetPost, post.ID)
+	}
+}
+
+// getPostReactionsHandler lists who reacted to the post (?type= to filter).
+func (app *application) getPostReactionsHandler(w http.ResponseWriter, r *http.Request) {
+	if post, ok := app.readablePostFromRoute(w, r); ok {
+		app.listReactions(w, r, dto.ReactionTargetPost, post.ID)
+	}
+}
+
+// reactToCommentHandler sets the reaction of the user to the comment, replacing their previous
+// one.
+func (app *application) reactToCommentHandler(w http.ResponseWriter, r *http.Request) {
+	if comment, ok := app.readableCommentFromRoute(w, r); ok {
+		app.react(w, r, dto.ReactionTargetComment, comment.ID)
+	}
+}
+
+func (app *application) deleteCommentReactionHandler(w http.ResponseWriter, r *http.Request) {
+	if comment, ok := app.readableCommentFromRoute(w, r); ok {
+		app.deleteReaction(w, r, dto.ReactionTargetComment, comment.ID)
+	}
+}
+
+// getCommentReactionsHandler lists who reacted to the comment (?type= to filter).
+func (app *application) getCommentReactionsHandler(w http.ResponseWriter, r *http.Request) {
+	if comment, ok := app.readableCommentFromRoute(w, r); ok {
+		app.listReactions(w, r, dto.ReactionTargetComment, comment.ID)
+	}
+}
+
+// react is idempotent, it responds with the updated reactions of the target.
+func (app *application) react(w http.ResponseWriter, r *http.Request, target string, targetID int64) {
+	var payload reactionPayload
+	if err := utils.ReadJSON(r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		app.failedValidationError(w, r, err)
+		return
+	}
+
+	if errMap := app.validateReactionType(payload.Type); errMap != nil {
+		app.failedValidationError(w, r, errMap)
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	if err := app.store.Reactions.Set(ctx, target, targetID, userID, payload.Type); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeReactionSummary(w, r, target, targetID)
+}
+
+// deleteReaction is idempotent, it responds with the updated reactions of the target.
+func (app *application) deleteReaction(w http.ResponseWriter, r *http.Request, target string, targetID int64) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	if err := app.store.Reactions.Delete(r.Context(), target, targetID, userID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeReactionSummary(w, r, target, targetID)
+}
+
+func (app *application) writeReactionSummary(w http.ResponseWriter, r *http.Request, target string, targetID int64) {
+	summaries, err := app.reactionSummaries(r.Context(), target, []int64{targetID}, middleware.GetAuthUserIDFromContext(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, summaries[targetID]); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) listReactions(w http.ResponseWriter, r *http.Request, target string, targetID int64) {
+	params := utils.ParseQueryParams(r)
+
+	var cursor int64
+	if params["cursor"] != "" {
+		c, err := strconv.ParseInt(params["cursor"], 10, 64)
+		if err != nil || c < 1 {
+			app.badRequestError(w, r, errors.New("invalid cursor"))
+			return
+		}
+		cursor = c
+	}
+
+	if params["type"] != "" {
+		if errMap := app.validateReactionType(params["type"]); errMap != nil {
+			app.failedValidationError(w, r, errMap)
+			return
+		}
+	}
+
+	queryParams := dto.ReactionQueryParams{
+		Limit:  utils.ParseIntWithDefaultAndMax(params["limit"], 25, 100),
+		Cursor: cursor,
+		Type:   params["type"],
+	}
+
+	viewerID := middleware.GetAuthUserIDFromContext(r)
+
+	reactions, err := app.store.Reactions.GetByTarget(r.Context(), target, targetID, viewerID, queryParams)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	resp := reactionsResponse{Reactions: reactions}
+	if len(reactions) == queryParams.Limit {
+		next := strconv.FormatInt(reactions[len(reactions)-1].ID, 10)
+		resp.NextCursor = &next
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) validateReactionType(reactionType string) map[string]string {
+	types := app.config.reactions.types
+	if !slices.Contains(types, reactionType) {
+		return map[string]string{"type": fmt.Sprintf("type must be one of %s", strings.Join(types, ", "))}
+	}
+	return nil
+}
+
+// reactionSummaries returns the reactions to the posts or comments, by ID, with a single query.
+// Every target has a summary, reactions or not.
+func (app *application) reactionSummaries(ctx context.Context, target string, targetIDs []int64, viewerID int64) (map[int64]dto.ReactionSummary, error) {
+	counts, err := app.store.Reactions.GetCounts(ctx, target, targetIDs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[int64]dto.ReactionSummary, len(targetIDs))
+	for _, id := range targetIDs {
+		summaries[id] = dto.ReactionSummary{Reactions: map[string]int{}}
+	}
+	for _, c := range counts {
+		summary := summaries[c.TargetID]
+		summary.Reactions[c.Type] = c.Count
+		if c.ViewerReacted {
+			summary.ViewerReaction = &c.Type
+		}
+		summaries[c.TargetID] = summary
+	}
+
+	return summaries, nil
+}
+
+// loadReactions sets the reactions to the posts, as seen by the viewer.
+func (app *application) loadReactions(ctx context.Context, viewerID int64, posts ...*dto.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	postIDs := make([]int64, len(posts))
+	for i, p := range posts {
+		postIDs[i] = p.ID
+	}
+
+	summaries, err := app.reactionSummaries(ctx, dto.ReactionTargetPost, postIDs, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		p.Reactions = summaries[p.ID].Reactions
+		p.ViewerReaction = summaries[p.ID].ViewerReaction
+	}
+
+	return nil
+}
+
+// loadCommentReactions sets the reactions to the comments, as seen by the viewer.
+func (app *application) loadCommentReactions(ctx context.Context, viewerID int64, comments ...*dto.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	commentIDs := make([]int64, len(comments))
+	for i, c := range comments {
+		commentIDs[i] = c.ID
+	}
+
+	summaries, err := app.reactionSummaries(ctx, dto.ReactionTargetComment, commentIDs, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range comments {
+		c.Reactions = summaries[c.ID].Reactions
+		c.ViewerReaction = summaries[c.ID].ViewerReaction
+	}
+
+	return nil
+}
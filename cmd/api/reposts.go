@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+)
+
+// repostHandler shares the post with the followers of the user. Only public posts can be
+// reposted. Reposting a repost reposts its original, and reposting a post twice is a no-op.
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.readablePostFromRoute(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	original, errMap, err := app.originalPost(ctx, post, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if errMap != nil {
+		app.failedValidationError(w, r, errMap)
+		return
+	}
+	if original.Visibility != dto.VisibilityPublic {
+		app.failedValidationError(w, r, map[string]string{"post": "only public posts can be reposted"})
+		return
+	}
+
+	var repost *dto.Post
+	var created bool
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		repost, created, err = app.store.Posts.Repost(ctx, userID, original.ID)
+		if err != nil || !created {
+			return err
+		}
+		return app.events.Publish(ctx, events.PostCreated{Post: *repost})
+	}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	repost.Comments = []dto.Comment{}
+	repost.RepostOf = original
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	if err := utils.JSONResponse(w, status, repost); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// deleteRepostHandler takes back the repost of the post by the user, if any, like a deleted
+// post. It doesn't matter whether the user may still read the post.
+func (app *application) deleteRepostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, errors.New("invalid post id"))
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		repostID, err := app.store.Posts.DeleteRepost(ctx, userID, postID)
+		if err != nil {
+			return err
+		}
+		return app.events.Publish(ctx, events.PostDeleted{PostID: repostID, UserID: userID})
+	}); err != nil && !errors.Is(err, errs.ErrNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// originalPost returns the post to repost or quote in place of the post, read by the user:
+// the post itself, or the original of a repost. Only published posts are shared.
+func (app *application) originalPost(ctx context.Context, post *dto.Post, userID int64) (*dto.Post, map[string]string, error) {
+	if post.RepostOfID != nil {
+		original, err := app.store.Posts.GetByID(ctx, *post.RepostOfID, userID)
+		if err != nil {
+			switch {
+			case errors.Is(err, errs.ErrNotFound), errors.Is(err, errs.ErrForbidden):
+				return nil, map[string]string{"post": "the reposted post is no longer available"}, nil
+			default:
+				return nil, nil, err
+			}
+		}
+		post = original
+	}
+
+	if post.Status != dto.PostStatusPublished {
+		return nil, map[string]string{"post": "only published posts can be shared"}, nil
+	}
+
+	post.Comments = []dto.Comment{}
+	return post, nil, nil
+}
+
+// quotedPost returns the post to quote, read by the user, see originalPost.
+func (app *application) quotedPost(ctx context.Context, postID, userID int64) (*dto.Post, map[string]string, error) {
+	unavailable := map[string]string{"quote_of_id": "the quoted post does not exist"}
+
+	post, err := app.store.Posts.GetByID(ctx, postID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound), errors.Is(err, errs.ErrForbidden):
+			return nil, unavailable, nil
+		default:
+			return nil, nil, err
+		}
+	}
+
+	original, errMap, err := app.originalPost(ctx, post, userID)
+	if errMap != nil {
+		errMap = map[string]string{"quote_of_id": errMap["post"]}
+	}
+	return original, errMap, err
+}
+
+// withOriginals returns the posts followed by the originals embedded in them (see
+// PostStore.EmbedOriginals), for their expansions to be loaded along.
+func withOriginals(posts ...*dto.Post) []*dto.Post {
+	all := append([]*dto.Post{}, posts...)
+	for _, p := range posts {
+		if p.RepostOf != nil {
+			all = append(all, p.RepostOf)
+		}
+		if p.QuoteOf != nil {
+			all = append(all, p.QuoteOf)
+		}
+	}
+	return all
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/stream"
+)
+
+// postRoute returns the ctx values routing a request to the post.
+func postRoute(postID int64) map[any]any {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("postID", strconv.FormatInt(postID, 10))
+	return map[any]any{chi.RouteCtxKey: rctx}
+}
+
+func TestRepostRules(t *testing.T) {
+	app, conn := newTestApplication(t)
+
+	author := createTestUser(t, app, "author")
+	reposter := createTestUser(t, app, "reposter")
+	follower := createTestUser(t, app, "follower")
+	if err := app.store.Followers.Follow(context.Background(), reposter.ID, follower.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	createPost := func(visibility string) int64 {
+		w := serve(t, app.createPostHandler, http.MethodPost, createPostPayload{
+			Title:      "Original",
+			Content:    "To be reposted",
+			Visibility: visibility,
+			UserID:     author.ID,
+		}, author.ID, nil)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create post: got status %d: %s", w.Code, w.Body)
+		}
+		var post dto.Post
+		if err := json.Unmarshal(w.Body.Bytes(), &post); err != nil {
+			t.Fatal(err)
+		}
+		return post.ID
+	}
+
+	unlisted := createPost(dto.VisibilityUnlisted)
+	if w := serve(t, app.repostHandler, http.MethodPut, nil, reposter.ID, postRoute(unlisted)); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("repost unlisted post: got status %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+
+	public := createPost(dto.VisibilityPublic)
+	w := serve(t, app.repostHandler, http.MethodPut, nil, reposter.ID, postRoute(public))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("repost: got status %d: %s", w.Code, w.Body)
+	}
+	var repost dto.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &repost); err != nil {
+		t.Fatal(err)
+	}
+
+	w = serve(t, app.reactToPostHandler, http.MethodPut, reactionPayload{Type: "like"}, follower.ID, postRoute(repost.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("react to repost: got status %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+
+	app.events.Wait()
+	sub := app.hub.Subscribe(stream.UserTopic(follower.ID))
+	defer sub.Close()
+
+	w = serve(t, app.deleteRepostHandler, http.MethodDelete, nil, reposter.ID, postRoute(public))
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete repost: got status %d: %s", w.Code, w.Body)
+	}
+
+	var rows int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM timelines WHERE post_id = $1`, repost.ID).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 0 {
+		t.Errorf("got %d timeline rows for the deleted repost, want 0", rows)
+	}
+
+	select {
+	case evt := <-sub.Events():
+		if evt.Type != stream.EventPostDeleted {
+			t.Errorf("got stream event %s, want %s", evt.Type, stream.EventPostDeleted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the follower was not told the repost is gone")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/markdown"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+)
+
+// readablePostFromRoute returns the post of the route if the user may read it. Otherwise the
+// error response is written and ok is false.
+func (app *application) readablePostFromRoute(w http.ResponseWriter, r *http.Request) (post *dto.Post, ok bool) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, errors.New("invalid post id"))
+		return nil, false
+	}
+
+	post, err = app.store.Posts.GetByID(r.Context(), postID, middleware.GetAuthUserIDFromContext(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		case errors.Is(err, errs.ErrForbidden):
+			app.forbiddenError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return post, true
+}
+
+// getPostRevisionsHandler returns the edit history of the post, latest revision first.
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.readablePostFromRoute(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.store.Revisions.GetByPostID(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getPostRevisionsDiffHandler compares two revisions of the post (?from=&to=). By default a
+// revision is compared to the one before it, and to defaults to the latest revision.
+func (app *application) getPostRevisionsDiffHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.readablePostFromRoute(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	revisions, err := app.store.Revisions.GetByPostID(ctx, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if len(revisions) == 0 {
+		app.notFoundError(w, r, errs.ErrNotFound)
+		return
+	}
+
+	params := utils.ParseQueryParams(r)
+	errMap := map[string]string{}
+
+	to := revisions[0].Revision
+	if raw := params["to"]; raw != "" {
+		if to, err = strconv.Atoi(raw); err != nil || to < 1 {
+			errMap["to"] = "to must be a revision number"
+		}
+	}
+	from := to - 1
+	if raw := params["from"]; raw != "" {
+		if from, err = strconv.Atoi(raw); err != nil || from < 1 {
+			errMap["from"] = "from must be a revision number"
+		}
+	}
+	if len(errMap) == 0 && from < 1 {
+		errMap["from"] = "the first revision has no previous revision"
+	}
+	if len(errMap) > 0 {
+		app.failedValidationError(w, r, errMap)
+		return
+	}
+
+	byRevision := make(map[int]dto.PostRevision, len(revisions))
+	for _, rev := range revisions {
+		byRevision[rev.Revision] = rev
+	}
+	fromRev, okFrom := byRevision[from]
+	toRev, okTo := byRevision[to]
+	if !okFrom || !okTo {
+		app.notFoundError(w, r, errs.ErrNotFound)
+		return
+	}
+
+	tagsAdded, tagsRemoved := utils.DiffSets(fromRev.Tags, toRev.Tags)
+	diff := dto.PostRevisionDiff{
+		PostID:      post.ID,
+		From:        from,
+		To:          to,
+		Title:       utils.DiffLines(fromRev.Title, toRev.Title),
+		Content:     utils.DiffLines(fromRev.Content, toRev.Content),
+		TagsAdded:   tagsAdded,
+		TagsRemoved: tagsRemoved,
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, diff); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// restorePostRevisionHandler brings back the title, content and tags of an earlier revision.
+// The restored version is recorded as a new revision, the history is never rewritten.
+func (app *application) restorePostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.readablePostFromRoute(w, r)
+	if !ok {
+		return
+	}
+
+	userID := middleware.GetAuthUserIDFromContext(r)
+	if post.UserID != userID {
+		app.forbiddenError(w, r, errors.New("only the author can restore a revision"))
+		return
+	}
+
+	revision, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil {
+		app.badRequestError(w, r, errors.New("invalid revision"))
+		return
+	}
+
+	ctx := r.Context()
+
+	rev, err := app.store.Revisions.GetByRevision(ctx, post.ID, revision)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post.Title = rev.Title
+	post.Content = rev.Content
+	post.ContentHTML = markdown.Render(rev.Content)
+	post.Tags = rev.Tags
+
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := app.store.Posts.Update(ctx, post); err != nil {
+			return err
+		}
+		if err := app.store.Revisions.Create(ctx, post.ID, userID); err != nil {
+			return err
+		}
+		mentions, added, err := app.saveMentions(ctx, post.ID, nil, post.Content)
+		if err != nil {
+			return err
+		}
+		post.Mentions = mentions
+		if post.Status != dto.PostStatusPublished {
+			return nil
+		}
+		if len(added) > 0 {
+			if err := app.events.Publish(ctx, events.UsersMentioned{ActorID: userID, PostID: post.ID, UserIDs: added}); err != nil {
+				return err
+			}
+		}
+		return app.events.Publish(ctx, events.PostUpdated{Post: *post})
+	}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	post.Comments = []dto.Comment{}
+
+	if err := utils.JSONResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+)
+
+type searchResponse struct {
+	Type    string          `json:"type"`
+	Query   string          `json:"q"`
+	Results []dto.SearchHit `json:"results"`
+	Page    int             `json:"page"`
+	Limit   int             `json:"limit"`
+}
+
+// searchHandler runs a full-text search over posts, comments or users (?type=, posts by
+// default). The query supports "quoted phrases" and -excluded terms, the rest of the syntax
+// depends on the search backend.
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	params := utils.ParseQueryParams(r)
+
+	queryParams := dto.SearchQueryParams{
+		Query: strings.TrimSpace(params["q"]),
+		Type:  params["type"],
+		Limit: utils.ParseIntWithDefaultAndMax(params["limit"], 20, 50),
+		Page:  utils.ParseIntWithDefaultAndMax(params["page"], 1, 0),
+	}
+	if queryParams.Type == "" {
+		queryParams.Type = dto.SearchTypePosts
+	}
+
+	if err := utils.ValidateStruct(&queryParams); err != nil {
+		app.failedValidationError(w, r, err)
+		return
+	}
+
+	viewerID := middleware.GetAuthUserIDFromContext(r)
+
+	hits, err := app.search.Query(r.Context(), viewerID, queryParams)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrInvalidQuery):
+			app.badRequestError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	resp := searchResponse{
+		Type:    queryParams.Type,
+		Query:   queryParams.Query,
+		Results: hits,
+		Page:    queryParams.Page,
+		Limit:   queryParams.Limit,
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/stream"
+)
+
+// Proxies drop idle connections, so a comment line is sent when nothing else was.
+const streamKeepAliveInterval = 25 * time.Second
+
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	rc := http.NewResponseController(w)
+
+	// The stream outlives the server WriteTimeout, clear the deadline for this connection.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	sub := app.hub.Subscribe(stream.UserTopic(userID))
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 5000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(streamKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case evt, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, evt.Data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// publish pushes events to connected clients. Like notifications, a failed push never fails
+// the request that caused it.
+func (app *application) publish(ctx context.Context, events ...stream.Event) {
+	if err := app.hub.Publish(ctx, events...); err != nil {
+		app.logger.Warnw("failed to publish stream events", "count", len(events), "error", err)
+	}
+}
+
+func (app *application) newStreamEvent(topic, eventType string, data any) (stream.Event, bool) {
+	evt, err := stream.NewEvent(topic, eventType, data)
+	if err != nil {
+		app.logger.Warnw("failed to encode stream event", "type", eventType, "error", err)
+		return stream.Event{}, false
+	}
+	return evt, true
+}
+
+// postCreatedEvent keeps the payload small: NOTIFY payloads are capped, and clients fetch
+// the full post when they render it.
+type postCreatedEvent struct {
+	ID        int64    `json:"id"`
+	UserID    int64    `json:"user_id"`
+	Title     string   `json:"title"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"created_at"`
+}
+
+func (app *application) publishPostCreated(ctx context.Context, post *dto.Post) {
+	if post.Visibility == dto.VisibilityPrivate {
+		return
+	}
+
+	followerIDs, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID)
+	if err != nil {
+		app.logger.Warnw("failed to load followers for stream", "user_id", post.UserID, "error", err)
+		return
+	}
+
+	data, err := json.Marshal(postCreatedEvent{
+		ID:        post.ID,
+		UserID:    post.UserID,
+		Title:     post.Title,
+		Tags:      post.Tags,
+		CreatedAt: post.CreatedAt,
+	})
+	if err != nil {
+		app.logger.Warnw("failed to encode stream event", "type", stream.EventPostCreated, "error", err)
+		return
+	}
+
+	events := make([]stream.Event, 0, len(followerIDs))
+	for _, id := range followerIDs {
+		events = append(events, stream.Event{Topic: stream.UserTopic(id), Type: stream.EventPostCreated, Data: data})
+	}
+	app.publish(ctx, events...)
+}
+
+// publishPostDeleted tells the followers of the author, pushed the post when it was created,
+// that it is gone.
+func (app *application) publishPostDeleted(ctx context.Context, postID, userID int64) {
+	followerIDs, err := app.store.Followers.GetFollowerIDs(ctx, userID)
+	if err != nil {
+		app.logger.Warnw("failed to load followers for stream", "user_id", userID, "error", err)
+		return
+	}
+
+	data, err := json.Marshal(map[string]int64{"id": postID, "user_id": userID})
+	if err != nil {
+		app.logger.Warnw("failed to encode stream event", "type", stream.EventPostDeleted, "error", err)
+		return
+	}
+
+	events := make([]stream.Event, 0, len(followerIDs))
+	for _, id := range followerIDs {
+		events = append(events, stream.Event{Topic: stream.UserTopic(id), Type: stream.EventPostDeleted, Data: data})
+	}
+	app.publish(ctx, events...)
+}
+
+// commentEvent identifies the comment, see postCreatedEvent: the content of a comment alone
+// can exceed the NOTIFY payload limit.
+type commentEvent struct {
+	ID        int64  `json:"id"`
+	PostID    int64  `json:"post_id"`
+	UserID    int64  `json:"user_id"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func newCommentEvent(comment *dto.Comment) commentEvent {
+	return commentEvent{
+		ID:        comment.ID,
+		PostID:    comment.PostID,
+		UserID:    comment.UserID,
+		UpdatedAt: comment.UpdatedAt,
+	}
+}
+
+func (app *application) publishCommentCreated(ctx context.Context, comment *dto.Comment, postAuthorID int64) {
+	var events []stream.Event
+
+	data := newCommentEvent(comment)
+	if evt, ok := app.newStreamEvent(stream.PostTopic(comment.PostID), stream.EventCommentCreated, data); ok {
+		events = append(events, evt)
+	}
+	if postAuthorID != comment.UserID {
+		if evt, ok := app.newStreamEvent(stream.UserTopic(postAuthorID), stream.EventCommentCreated, data); ok {
+			events = append(events, evt)
+		}
+	}
+	app.publish(ctx, events...)
+}
+
+func (app *application) publishNotification(ctx context.Context, n *dto.Notification) {
+	if evt, ok := app.newStreamEvent(stream.UserTopic(n.UserID), stream.EventNotification, n); ok {
+		app.publish(ctx, evt)
+	}
+}
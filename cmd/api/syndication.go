@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/syndication"
+	"github.com/mafi020/social/internal/utils"
+)
+
+func (app *application) getUserRSSFeedHandler(w http.ResponseWriter, r *http.Request) {
+	app.userSyndicationFeed(w, r, syndication.RSS, syndication.RSSContentType)
+}
+
+func (app *application) getUserAtomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	app.userSyndicationFeed(w, r, syndication.Atom, syndication.AtomContentType)
+}
+
+func (app *application) userSyndicationFeed(w http.ResponseWriter, r *http.Request, render func(syndication.Feed) ([]byte, error), contentType string) {
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByUsername(ctx, chi.URLParam(r, "username"))
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	posts, err := app.store.Posts.GetLatestByUserID(ctx, user.ID, app.config.syndication.limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	updated, err := time.Parse(time.RFC3339Nano, user.CreatedAt)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	baseURL := app.config.baseURL
+	userURL := fmt.Sprintf("%s/api/users/%d", baseURL, user.ID)
+	feed := syndication.Feed{
+		ID:          userURL,
+		Title:       user.UserName,
+		Description: "Latest posts of " + user.UserName,
+		Link:        userURL,
+		SelfLink:    baseURL + r.URL.Path,
+		Updated:     updated,
+	}
+
+	app.serveSyndicationFeed(w, r, feed, posts, render, contentType)
+}
+
+func (app *application) getTagAtomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := utils.NormalizeTag(chi.URLParam(r, "tag"))
+	if !ok {
+		app.badRequestError(w, r, errors.New("invalid tag"))
+		return
+	}
+
+	posts, err := app.store.Posts.GetLatestByTag(r.Context(), tag, app.config.syndication.limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// A feed needs an update time, a tag nobody used has none
+	if len(posts) == 0 {
+		app.notFoundError(w, r, errs.ErrNotFound)
+		return
+	}
+
+	baseURL := app.config.baseURL
+	tagURL := baseURL + "/api/tags/" + url.PathEscape(tag) + "/posts"
+	feed := syndication.Feed{
+		ID:          tagURL,
+		Title:       "#" + tag,
+		Description: "Latest posts tagged " + tag,
+		Link:        tagURL,
+		SelfLink:    baseURL + r.URL.Path,
+	}
+
+	app.serveSyndicationFeed(w, r, feed, posts, syndication.Atom, syndication.AtomContentType)
+}
+
+// serveSyndicationFeed renders the posts into the feed. Responses carry an ETag and a
+// Last-Modified header, conditional requests of feed readers polling for changes are answered
+// with 304 Not Modified.
+func (app *application) serveSyndicationFeed(w http.ResponseWriter, r *http.Request, feed syndication.Feed, posts []dto.Post, render func(syndication.Feed) ([]byte, error), contentType string) {
+	for _, p := range posts {
+		entry, err := app.syndicationEntry(p)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	body, err := render(feed)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	http.ServeContent(w, r, "", feed.LastModified(), bytes.NewReader(body))
+}
+
+func (app *application) syndicationEntry(p dto.Post) (syndication.Entry, error) {
+	published, err := time.Parse(time.RFC3339Nano, p.CreatedAt)
+	if err != nil {
+		return syndication.Entry{}, err
+	}
+	updated, err := time.Parse(time.RFC3339Nano, p.UpdatedAt)
+	if err != nil {
+		return syndication.Entry{}, err
+	}
+
+	link := fmt.Sprintf("%s/api/posts/%d", app.config.baseURL, p.ID)
+	return syndication.Entry{
+		ID:         link,
+		Title:      p.Title,
+		Link:       link,
+		Content:    p.Content,
+		Author:     p.User.UserName,
+		Categories: p.Tags,
+		Published:  published,
+		Updated:    updated,
+	}, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/trending"
+	"github.com/mafi020/social/internal/utils"
+)
+
+// getTagsHandler autocompletes tags (?prefix=), the prefix being normalized like the tags.
+func (app *application) getTagsHandler(w http.ResponseWriter, r *http.Request) {
+	params := utils.ParseQueryParams(r)
+
+	prefix, ok := utils.NormalizeTag(params["prefix"])
+	if !ok {
+		app.failedValidationError(w, r, map[string]string{"prefix": fmt.Sprintf("prefix is 1 to %d characters long", utils.MaxTagLength)})
+		return
+	}
+	limit := utils.ParseIntWithDefaultAndMax(params["limit"], 10, 50)
+
+	tags, err := app.store.Tags.Autocomplete(r.Context(), prefix, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getTrendingTagsHandler serves the trending tags of a window (?window=1h|24h|7d, 24h by
+// default), as last computed by the trending job.
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	params := utils.ParseQueryParams(r)
+
+	window := params["window"]
+	if window == "" {
+		window = "24h"
+	}
+	limit := utils.ParseIntWithDefaultAndMax(params["limit"], 10, app.config.trending.Limit)
+
+	res, err := app.trending.Get(r.Context(), window)
+	if err != nil {
+		switch {
+		case errors.Is(err, trending.ErrUnknownWindow):
+			app.failedValidationError(w, r, map[string]string{"window": "window must be one of 1h, 24h, 7d"})
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if len(res.Tags) > limit {
+		res.Tags = res.Tags[:limit]
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) getDeniedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.store.Tags.GetDeniedTags(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type denyTagPayload struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// denyTagHandler suppresses the tag, normalized like the tags of the posts, from the trending
+// tags and the autocompletion.
+func (app *application) denyTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := utils.NormalizeTag(chi.URLParam(r, "tag"))
+	if !ok {
+		app.badRequestError(w, r, errors.New("invalid tag"))
+		return
+	}
+
+	var payload denyTagPayload
+	if r.ContentLength != 0 {
+		if err := utils.ReadJSON(r, &payload); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		app.failedValidationError(w, r, err)
+		return
+	}
+
+	moderatorID := middleware.GetAuthUserIDFromContext(r)
+	denied := &dto.DeniedTag{
+		Tag:       tag,
+		Reason:    payload.Reason,
+		CreatedBy: &moderatorID,
+	}
+
+	if err := app.store.Tags.DenyTag(r.Context(), denied); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.trending.Suppress(denied.Tag)
+
+	if err := utils.JSONResponse(w, http.StatusOK, denied); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) allowTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := utils.NormalizeTag(chi.URLParam(r, "tag"))
+	if !ok {
+		app.badRequestError(w, r, errors.New("invalid tag"))
+		return
+	}
+
+	if err := app.store.Tags.AllowTag(r.Context(), tag); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, map[string]string{"message": "Tag removed from the deny-list"}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
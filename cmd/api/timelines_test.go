@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+// TestTimelinesFollowTheEvents goes through the handlers: the side effects on the timelines
+// run on the events published in their transactions.
+func TestTimelinesFollowTheEvents(t *testing.T) {
+	app, conn := newTestApplication(t)
+
+	author := createTestUser(t, app, "author")
+	follower := createTestUser(t, app, "follower")
+
+	w := serve(t, app.followUserHandler, http.MethodPut, nil, follower.ID, map[any]any{targetUserCtx: author})
+	if w.Code != http.StatusOK {
+		t.Fatalf("follow: got status %d: %s", w.Code, w.Body)
+	}
+
+	w = serve(t, app.createPostHandler, http.MethodPost, createPostPayload{
+		Title:   "Fanned out",
+		Content: "Written to the timelines on publication",
+		UserID:  author.ID,
+	}, author.ID, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create post: got status %d: %s", w.Code, w.Body)
+	}
+	var post dto.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &post); err != nil {
+		t.Fatal(err)
+	}
+
+	var fannedOut bool
+	if err := conn.QueryRow(`SELECT fanned_out_at IS NOT NULL FROM posts WHERE id = $1`, post.ID).Scan(&fannedOut); err != nil {
+		t.Fatal(err)
+	}
+	if !fannedOut {
+		t.Error("the post was not fanned out")
+	}
+
+	timelineOwners := func() map[int64]bool {
+		rows, err := conn.Query(`SELECT user_id FROM timelines WHERE post_id = $1`, post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+
+		owners := map[int64]bool{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			owners[id] = true
+		}
+		return owners
+	}
+
+	if owners := timelineOwners(); !owners[author.ID] || !owners[follower.ID] || len(owners) != 2 {
+		t.Fatalf("got the post in the timelines of %v, want the author %d and the follower %d", owners, author.ID, follower.ID)
+	}
+
+	w = serve(t, app.unfollowUserHandler, http.MethodPut, nil, follower.ID, map[any]any{targetUserCtx: author})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unfollow: got status %d: %s", w.Code, w.Body)
+	}
+
+	if owners := timelineOwners(); owners[follower.ID] || !owners[author.ID] {
+		t.Fatalf("got the post in the timelines of %v after the unfollow, want the author %d only", owners, author.ID)
+	}
+}
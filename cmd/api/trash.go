@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+)
+
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+	ctx := r.Context()
+
+	posts, err := app.store.Posts.GetDeletedByUserID(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range posts {
+		posts[i].Comments = []dto.Comment{}
+	}
+
+	comments, err := app.store.Comments.GetDeletedByUserID(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	trash := dto.Trash{
+		Posts:          posts,
+		Comments:       comments,
+		PurgeAfterDays: int(app.config.trash.Retention / (24 * time.Hour)),
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, trash); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// restorePostHandler takes a post of the user out of the trash. Posts of other users are not
+// found, as is a post purged from the trash.
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, errors.New("invalid post id"))
+		return
+	}
+
+	userID := middleware.GetAuthUserIDFromContext(r)
+	ctx := r.Context()
+
+	var post *dto.Post
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		post, err = app.store.Posts.Restore(ctx, postID, userID)
+		if err != nil {
+			return err
+		}
+		return app.events.Publish(ctx, events.PostRestored{Post: *post})
+	}); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post.DeletedAt = nil
+	post.Comments = []dto.Comment{}
+
+	if err := utils.JSONResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// restoreCommentHandler takes a comment of the user out of the trash.
+func (app *application) restoreCommentHandler(w http.ResponseWriter, r *http.Request) {
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, errors.New("invalid comment ID"))
+		return
+	}
+
+	userID := middleware.GetAuthUserIDFromContext(r)
+	ctx := r.Context()
+
+	var comment *dto.Comment
+	if err := app.store.WithTx(ctx, func(ctx context.Context) error {
+		comment, err = app.store.Comments.Restore(ctx, commentID, userID)
+		if err != nil {
+			return err
+		}
+		return app.events.Publish(ctx, events.CommentRestored{Comment: *comment})
+	}); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
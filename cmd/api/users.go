@@ -3,13 +3,16 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/mafi020/social/internal/dto"
 	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/events"
 	"github.com/mafi020/social/internal/middleware"
 	"github.com/mafi020/social/internal/utils"
 )
@@ -79,7 +82,12 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := app.store.Followers.Follow(r.Context(), targetUser.ID, loggedInUserID); err != nil {
+	if err := app.store.WithTx(r.Context(), func(ctx context.Context) error {
+		if err := app.store.Followers.Follow(ctx, targetUser.ID, loggedInUserID); err != nil {
+			return err
+		}
+		return app.events.Publish(ctx, events.UserFollowed{UserID: targetUser.ID, FollowerID: loggedInUserID})
+	}); err != nil {
 		switch {
 		case errors.Is(err, errs.ErrDuplicateEntry):
 			app.badRequestError(w, r, err)
@@ -103,8 +111,50 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	ctx := r.Context()
-	if err := app.store.Followers.UnFollow(ctx, targetUser.ID, loggedInUserID); err != nil {
+	if err := app.store.WithTx(r.Context(), func(ctx context.Context) error {
+		if err := app.store.Followers.UnFollow(ctx, targetUser.ID, loggedInUserID); err != nil {
+			return err
+		}
+		return app.events.Publish(ctx, events.UserUnfollowed{UserID: targetUser.ID, FollowerID: loggedInUserID})
+	}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateUserRelation(w, r, "block", app.store.Blocks.Block)
+}
+
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateUserRelation(w, r, "unblock", app.store.Blocks.Unblock)
+}
+
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateUserRelation(w, r, "mute", app.store.Blocks.Mute)
+}
+
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateUserRelation(w, r, "unmute", app.store.Blocks.Unmute)
+}
+
+// updateUserRelation applies a block or mute change from the logged in user to the user of
+// the route.
+func (app *application) updateUserRelation(w http.ResponseWriter, r *http.Request, action string, update func(ctx context.Context, userID, targetID int64) error) {
+	targetUser := getTargetUserFromContext(r)
+	loggedInUserID := middleware.GetAuthUserIDFromContext(r)
+
+	if targetUser.ID == loggedInUserID {
+		app.badRequestError(w, r, fmt.Errorf("you cannot %s yourself", action))
+		return
+	}
+
+	if err := update(r.Context(), loggedInUserID, targetUser.ID); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -116,10 +166,24 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 }
 
 type feedResponse struct {
-	Feed       []dto.Feed     `json:"feed"`
-	Pagination dto.Pagination `json:"pagination"`
+	Feed       []dto.Feed      `json:"feed"`
+	Pagination *dto.Pagination `json:"pagination,omitempty"`
+
+	// Cursor mode
+	NextCursor       *string `json:"next_cursor,omitempty"`
+	PrevCursor       *string `json:"prev_cursor,omitempty"`
+	ApproximateTotal *int    `json:"approximate_total,omitempty"`
 }
 
+// feedApproximateTotalMax caps the count returned in cursor mode.
+const feedApproximateTotalMax = 1000
+
+// getUserFeedHandler serves the feed page by page (?page=) or, when the cursor parameter is
+// present, by keyset cursors: an empty cursor returns the newest posts, next_cursor pages
+// towards older posts and prev_cursor towards newer ones.
+//
+// With sort=top posts are ranked instead (page mode only), explain=true returns the score of
+// each post. include= expands each post, see parseFeedIncludes.
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetAuthUserIDFromContext(r)
 
@@ -127,7 +191,7 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 
 	page := utils.ParseIntWithDefaultAndMax(params["page"], 1, 0)
 	limit := utils.ParseIntWithDefaultAndMax(params["limit"], 25, 100)
-	tags := utils.ParseCSV(params["tags"])
+	tags, _ := utils.NormalizeTags(utils.ParseCSV(params["tags"]))
 	search := params["search"]
 
 	queryParams := dto.FeedQueryParams{
@@ -135,6 +199,32 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		Limit:  limit,
 		Tags:   tags,
 		Search: search,
+		Sort:   params["sort"],
+	}
+
+	if queryParams.Sort != "" && queryParams.Sort != "latest" && queryParams.Sort != "top" {
+		app.failedValidationError(w, r, map[string]string{"sort": "sort must be one of latest, top"})
+		return
+	}
+
+	includes, errMap := parseFeedIncludes(params)
+	if errMap != nil {
+		app.failedValidationError(w, r, errMap)
+		return
+	}
+
+	if queryParams.Sort == "top" {
+		if r.URL.Query().Has("cursor") {
+			app.badRequestError(w, r, errors.New("cursor pagination is not supported with sort=top"))
+			return
+		}
+		app.getUserTopFeed(w, r, userID, queryParams, includes, params["explain"] == "true")
+		return
+	}
+
+	if r.URL.Query().Has("cursor") {
+		app.getUserFeedByCursor(w, r, userID, queryParams, includes, params)
+		return
 	}
 
 	feed, totalCount, err := app.store.Posts.Feed(r.Context(), userID, queryParams)
@@ -143,7 +233,10 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	log.Printf("Feeds %v", feed)
+	if err := app.expandFeed(r.Context(), userID, feed, includes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 
 	if len(feed) == 0 {
 		feed = []dto.Feed{}
@@ -151,7 +244,7 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 
 	resp := feedResponse{
 		Feed: feed,
-		Pagination: dto.Pagination{
+		Pagination: &dto.Pagination{
 			Page:       queryParams.Page,
 			Limit:      queryParams.Limit,
 			TotalCount: totalCount,
@@ -165,6 +258,244 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+func (app *application) getUserTopFeed(w http.ResponseWriter, r *http.Request, userID int64, queryParams dto.FeedQueryParams, includes dto.FeedIncludes, explain bool) {
+	feed, totalCount, err := app.store.Posts.TopFeed(r.Context(), userID, queryParams, *app.config.feedRanking)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.expandFeed(r.Context(), userID, feed, includes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if len(feed) == 0 {
+		feed = []dto.Feed{}
+	}
+
+	if !explain {
+		for i := range feed {
+			feed[i].Score = nil
+		}
+	}
+
+	resp := feedResponse{
+		Feed: feed,
+		Pagination: &dto.Pagination{
+			Page:       queryParams.Page,
+			Limit:      queryParams.Limit,
+			TotalCount: totalCount,
+			TotalPages: (totalCount + queryParams.Limit - 1) / queryParams.Limit,
+		},
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) getUserFeedByCursor(w http.ResponseWriter, r *http.Request, userID int64, queryParams dto.FeedQueryParams, includes dto.FeedIncludes, params map[string]string) {
+	if err := parseFeedCursor(params["cursor"], &queryParams); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	feed, hasMore, err := app.store.Posts.FeedByCursor(r.Context(), userID, queryParams)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.expandFeed(r.Context(), userID, feed, includes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	resp, err := newFeedCursorResponse(feed, hasMore, queryParams)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if params["include_total"] == "true" {
+		total, err := app.store.Posts.CountFeed(r.Context(), userID, queryParams, feedApproximateTotalMax)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		resp.ApproximateTotal = &total
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// parseFeedCursor sets the keyset position of the query from the cursor parameter, an empty
+// cursor starts from the newest posts.
+func parseFeedCursor(raw string, queryParams *dto.FeedQueryParams) error {
+	if raw == "" {
+		return nil
+	}
+
+	cursor, backward, err := utils.DecodeFeedCursor(raw)
+	if err != nil {
+		return err
+	}
+	queryParams.Cursor = cursor
+	queryParams.Backward = backward
+	return nil
+}
+
+// newFeedCursorResponse builds the response of a cursor paginated page of posts.
+func newFeedCursorResponse(feed []dto.Feed, hasMore bool, queryParams dto.FeedQueryParams) (feedResponse, error) {
+	if len(feed) == 0 {
+		feed = []dto.Feed{}
+	}
+
+	resp := feedResponse{Feed: feed}
+
+	if len(feed) > 0 {
+		first, err := utils.FeedCursorOf(feed[0].Post)
+		if err != nil {
+			return resp, err
+		}
+		last, err := utils.FeedCursorOf(feed[len(feed)-1].Post)
+		if err != nil {
+			return resp, err
+		}
+
+		// Newer posts may be published at any time, so prev_cursor is always returned to let
+		// clients poll for them. Going backward, older posts are known to exist.
+		prev := utils.EncodeFeedCursor(first, true)
+		resp.PrevCursor = &prev
+		if hasMore || queryParams.Backward {
+			next := utils.EncodeFeedCursor(last, false)
+			resp.NextCursor = &next
+		}
+	} else if queryParams.Cursor != nil {
+		prev := utils.EncodeFeedCursor(*queryParams.Cursor, true)
+		resp.PrevCursor = &prev
+	}
+
+	return resp, nil
+}
+
+// feedCommentsPreviewMax caps the number of comments previewed per post.
+const feedCommentsPreviewMax = 10
+
+// parseFeedIncludes reads the expansions requested with include=comments_preview,author,reactions
+// and the number of comments previewed per post (comments_preview_limit, 3 by default).
+func parseFeedIncludes(params map[string]string) (dto.FeedIncludes, map[string]string) {
+	includes := dto.FeedIncludes{
+		CommentsPreviewLimit: utils.ParseIntWithDefaultAndMax(params["comments_preview_limit"], 3, feedCommentsPreviewMax),
+	}
+
+	for _, include := range utils.ParseCSV(params["include"]) {
+		switch include {
+		case "comments_preview":
+			includes.CommentsPreview = true
+		case "author":
+			includes.Author = true
+		case "reactions":
+			includes.Reactions = true
+		default:
+			return includes, map[string]string{"include": fmt.Sprintf("unknown expansion %q, must be one of comments_preview, author, reactions", include)}
+		}
+	}
+
+	return includes, nil
+}
+
+// expandFeed loads the attachments, the mentions and the expansions of a page of posts, with one
+// query per expansion whatever the size of the page.
+func (app *application) expandFeed(ctx context.Context, viewerID int64, feed []dto.Feed, includes dto.FeedIncludes) error {
+	if len(feed) == 0 {
+		return nil
+	}
+
+	if includes.CommentsPreview {
+		postIDs := make([]int64, len(feed))
+		for i, f := range feed {
+			postIDs[i] = f.ID
+		}
+
+		comments, err := app.store.Comments.GetLatestByPostIDs(ctx, viewerID, postIDs, includes.CommentsPreviewLimit)
+		if err != nil {
+			return err
+		}
+
+		byPost := make(map[int64][]dto.Comment, len(feed))
+		for _, c := range comments {
+			byPost[c.PostID] = append(byPost[c.PostID], c)
+		}
+		for i := range feed {
+			if c, ok := byPost[feed[i].ID]; ok {
+				feed[i].Comments = c
+			}
+		}
+
+		previews := make([]*dto.Comment, 0, len(comments))
+		for i := range feed {
+			for j := range feed[i].Comments {
+				previews = append(previews, &feed[i].Comments[j])
+			}
+		}
+		if err := app.loadCommentMentions(ctx, previews...); err != nil {
+			return err
+		}
+		if includes.Reactions {
+			if err := app.loadCommentReactions(ctx, viewerID, previews...); err != nil {
+				return err
+			}
+		}
+	}
+
+	posts := make([]*dto.Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+	posts = withOriginals(posts...)
+	if err := app.loadAttachments(ctx, posts...); err != nil {
+		return err
+	}
+	if err := app.loadMentions(ctx, posts...); err != nil {
+		return err
+	}
+	if includes.Reactions {
+		if err := app.loadReactions(ctx, viewerID, posts...); err != nil {
+			return err
+		}
+	}
+
+	if includes.Author {
+		authorIDs := make([]int64, 0, len(feed))
+		for _, f := range feed {
+			if !slices.Contains(authorIDs, f.UserID) {
+				authorIDs = append(authorIDs, f.UserID)
+			}
+		}
+
+		profiles, err := app.store.Users.GetProfilesByIDs(ctx, viewerID, authorIDs)
+		if err != nil {
+			return err
+		}
+
+		byID := make(map[int64]*dto.UserProfile, len(profiles))
+		for i := range profiles {
+			byID[profiles[i].ID] = &profiles[i]
+		}
+		for i := range feed {
+			feed[i].Author = byID[feed[i].UserID]
+		}
+	}
+
+	return nil
+}
+
 /* ---------------User Context Middleware----------- */
 func (app *application) userFromRouteMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -197,3 +528,26 @@ func getTargetUserFromContext(r *http.Request) *dto.User {
 	user, _ := r.Context().Value(targetUserCtx).(*dto.User)
 	return user
 }
+
+// moderatorMiddleware restricts the routes to moderators.
+func (app *application) moderatorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := app.store.Users.GetById(r.Context(), middleware.GetAuthUserIDFromContext(r))
+		if err != nil {
+			switch {
+			case errors.Is(err, errs.ErrNotFound):
+				app.unAuthorizedError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if !user.IsModerator {
+			app.forbiddenError(w, r, errors.New("moderator access required"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
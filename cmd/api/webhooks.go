@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/utils"
+	"github.com/mafi020/social/internal/webhooks"
+)
+
+type webhookKey string
+
+const webhookCtx webhookKey = "webhook"
+
+type createWebhookPayload struct {
+	URL        string   `json:"url" validate:"required,url,max=2048"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=post.created comment.created user.followed invitation.accepted"`
+}
+
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var payload createWebhookPayload
+
+	if err := utils.ReadJSON(r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		app.failedValidationError(w, r, err)
+		return
+	}
+
+	if u, err := url.Parse(payload.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		app.failedValidationError(w, r, map[string]string{"url": "URL must be an http or https URL"})
+		return
+	}
+	if !app.config.webhooks.allowPrivateNetworks {
+		if err := webhooks.ValidateURL(r.Context(), payload.URL); err != nil {
+			app.failedValidationError(w, r, map[string]string{"url": "URL must resolve to a public address"})
+			return
+		}
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	sub := &dto.WebhookSubscription{
+		UserID:     middleware.GetAuthUserIDFromContext(r),
+		URL:        payload.URL,
+		Secret:     secret,
+		EventTypes: payload.EventTypes,
+	}
+
+	if err := app.store.Webhooks.CreateSubscription(r.Context(), sub); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusCreated, sub); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	subs, err := app.store.Webhooks.GetSubscriptionsByUserID(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, subs); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	sub := getWebhookFromContext(r)
+
+	if err := utils.JSONResponse(w, http.StatusOK, sub); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	sub := getWebhookFromContext(r)
+
+	if err := app.store.Webhooks.DeleteSubscription(r.Context(), sub.UserID, sub.ID); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, map[string]string{"message": "Webhook deleted successfully"}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type webhookDeliveriesResponse struct {
+	Deliveries []dto.WebhookDelivery `json:"deliveries"`
+	NextCursor *string               `json:"next_cursor"`
+}
+
+func (app *application) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	sub := getWebhookFromContext(r)
+
+	params := utils.ParseQueryParams(r)
+
+	var cursor int64
+	if params["cursor"] != "" {
+		c, err := strconv.ParseInt(params["cursor"], 10, 64)
+		if err != nil || c < 1 {
+			app.badRequestError(w, r, errors.New("invalid cursor"))
+			return
+		}
+		cursor = c
+	}
+
+	queryParams := dto.WebhookDeliveryQueryParams{
+		Limit:  utils.ParseIntWithDefaultAndMax(params["limit"], 25, 100),
+		Cursor: cursor,
+	}
+
+	deliveries, err := app.store.Webhooks.GetDeliveriesBySubscriptionID(r.Context(), sub.ID, queryParams)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	resp := webhookDeliveriesResponse{Deliveries: deliveries}
+	if len(deliveries) == queryParams.Limit {
+		next := strconv.FormatInt(deliveries[len(deliveries)-1].ID, 10)
+		resp.NextCursor = &next
+	}
+
+	if err := utils.JSONResponse(w, http.StatusOK, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// enqueueWebhook schedules deliveries of the event to its subscribers, they are sent by the
+// webhook worker.
+func (app *application) enqueueWebhook(ctx context.Context, eventType string, data any) error {
+	payload, err := webhooks.NewPayload(eventType, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = app.store.Webhooks.Enqueue(ctx, eventType, payload)
+	return err
+}
+
+/* ---------------Webhook Context Middleware----------- */
+// webhookFromRouteMiddleware loads the subscription from the route. Subscriptions of other
+// users are reported as not found.
+func (app *application) webhookFromRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paramWebhookID := chi.URLParam(r, "webhookID")
+		webhookID, err := strconv.ParseInt(paramWebhookID, 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, errors.New("invalid webhook ID"))
+			return
+		}
+
+		ctx := r.Context()
+		sub, err := app.store.Webhooks.GetSubscriptionByID(ctx, webhookID)
+		if err != nil {
+			switch {
+			case errors.Is(err, errs.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if sub.UserID != middleware.GetAuthUserIDFromContext(r) {
+			app.notFoundError(w, r, errs.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, webhookCtx, sub)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getWebhookFromContext(r *http.Request) *dto.WebhookSubscription {
+	sub, _ := r.Context().Value(webhookCtx).(*dto.WebhookSubscription)
+	return sub
+}
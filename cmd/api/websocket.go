@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/middleware"
+	"github.com/mafi020/social/internal/stream"
+)
+
+const (
+	// Time allowed to write a message to the client.
+	wsWriteWait = 10 * time.Second
+	// The client must answer a ping within this time.
+	wsPongWait = 60 * time.Second
+	// Pings are sent a bit more often than wsPongWait.
+	wsPingPeriod = (wsPongWait * 9) / 10
+	// Client messages are small control frames.
+	wsMaxMessageSize = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// Messages sent by the client.
+type wsClientMessage struct {
+	Type  string `json:"type"` // subscribe | unsubscribe | typing
+	Topic string `json:"topic"`
+}
+
+// Replies to client messages, sent alongside the stream events.
+type wsReply struct {
+	Type  string `json:"type"` // subscribed | unsubscribed | error
+	Topic string `json:"topic,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type typingEvent struct {
+	UserID int64 `json:"user_id"`
+}
+
+func (app *application) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetAuthUserIDFromContext(r)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with an HTTP error.
+		app.logger.Warnw("websocket upgrade failed", "user_id", userID, "error", err)
+		return
+	}
+	defer conn.Close()
+
+	sub := app.hub.Subscribe(stream.UserTopic(userID))
+	defer sub.Close()
+
+	// Replies are written by the write loop only; gorilla connections allow one concurrent writer.
+	replies := make(chan wsReply, 16)
+	readDone := make(chan struct{})
+
+	go app.websocketReadLoop(r.Context(), conn, sub, userID, replies, readDone)
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-readDone:
+			return
+
+		case evt, ok := <-sub.Events():
+			if !ok {
+				app.closeWebsocket(conn, websocket.CloseGoingAway, "server shutting down")
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(evt); err != nil {
+				return
+			}
+
+		case reply := <-replies:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(reply); err != nil {
+				return
+			}
+
+		case <-sub.Lagged():
+			// Dropping events silently would leave the client with a broken view; make it
+			// reconnect and refetch instead.
+			app.closeWebsocket(conn, websocket.CloseTryAgainLater, "client too slow")
+			return
+
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (app *application) websocketReadLoop(ctx context.Context, conn *websocket.Conn, sub *stream.Subscription, userID int64, replies chan<- wsReply, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var msg wsClientMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				app.logger.Debugw("websocket read failed", "user_id", userID, "error", err)
+			}
+			return
+		}
+
+		reply := app.handleWebsocketMessage(ctx, sub, userID, msg)
+		if reply == nil {
+			continue
+		}
+
+		select {
+		case replies <- *reply:
+		default:
+			// The write loop is backed up, the client will be disconnected as lagging anyway.
+		}
+	}
+}
+
+func (app *application) handleWebsocketMessage(ctx context.Context, sub *stream.Subscription, userID int64, msg wsClientMessage) *wsReply {
+	switch msg.Type {
+	case "subscribe":
+		if err := app.authorizeTopic(ctx, userID, msg.Topic); err != nil {
+			return &wsReply{Type: "error", Topic: msg.Topic, Error: err.Error()}
+		}
+		sub.Add(msg.Topic)
+		return &wsReply{Type: "subscribed", Topic: msg.Topic}
+
+	case "unsubscribe":
+		// The user topic carries notifications and can't be dropped.
+		if msg.Topic != stream.UserTopic(userID) {
+			sub.Remove(msg.Topic)
+		}
+		return &wsReply{Type: "unsubscribed", Topic: msg.Topic}
+
+	case "typing":
+		if !sub.Has(msg.Topic) {
+			return &wsReply{Type: "error", Topic: msg.Topic, Error: "not subscribed to topic"}
+		}
+		if evt, ok := app.newStreamEvent(msg.Topic, stream.EventTyping, typingEvent{UserID: userID}); ok {
+			app.publish(ctx, evt)
+		}
+		return nil
+
+	default:
+		return &wsReply{Type: "error", Error: "unknown message type"}
+	}
+}
+
+// authorizeTopic checks that the user may listen to the topic. A user topic carries private
+// notifications, so only its owner may subscribe; post topics require the user to be allowed
+// to read the post.
+func (app *application) authorizeTopic(ctx context.Context, userID int64, topic string) error {
+	kind, id, err := stream.ParseTopic(topic)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case stream.TopicUser:
+		if id != userID {
+			return errs.ErrUnauthorized
+		}
+		return nil
+
+	case stream.TopicPost:
+		if _, err := app.store.Posts.GetByID(ctx, id, userID); err != nil {
+			if errors.Is(err, errs.ErrNotFound) || errors.Is(err, errs.ErrForbidden) {
+				return err
+			}
+			app.logger.Warnw("failed to authorize websocket topic", "topic", topic, "error", err)
+			return errors.New("could not subscribe, try again")
+		}
+		return nil
+	}
+
+	return stream.ErrInvalidTopic
+}
+
+func (app *application) closeWebsocket(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait)); err != nil {
+		app.logger.Debugw("websocket close failed", "error", err)
+	}
+}
@@ -0,0 +1,16 @@
+CREATE TYPE notification_type AS ENUM ('follow', 'comment', 'invitation_accepted');
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- recipient
+    actor_id BIGINT REFERENCES users(id) ON DELETE CASCADE,         -- NULL when the actor is not a user yet
+    type notification_type NOT NULL,
+    post_id BIGINT REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    data JSONB NOT NULL DEFAULT '{}',
+    read_at TIMESTAMP,                                              -- NULL = unread
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_id ON notifications(user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
@@ -0,0 +1,6 @@
+-- Keyset pagination of the feed orders posts by (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_posts_created_at_id ON posts (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_created_at_id ON posts (user_id, created_at DESC, id DESC);
+
+-- Accounts followed by a user
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id ON followers (follower_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_posts_not_fanned_out;
+ALTER TABLE posts DROP COLUMN IF EXISTS fanned_out_at;
+
+DROP TABLE IF EXISTS timelines;
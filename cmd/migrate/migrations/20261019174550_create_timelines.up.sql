@@ -0,0 +1,19 @@
+-- Home timelines materialized on write: one row per post for the author and each of their
+-- followers. created_at is copied from the post so a timeline can be paginated on its own index.
+CREATE TABLE IF NOT EXISTS timelines (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- owner of the timeline
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    author_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL,
+
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_timelines_user_id_created_at ON timelines (user_id, created_at DESC, post_id DESC);
+CREATE INDEX IF NOT EXISTS idx_timelines_user_id_author_id ON timelines (user_id, author_id);
+
+-- NULL until the post has been written to the timelines. Feeds pull those posts from the posts
+-- table instead: posts of accounts with too many followers are never fanned out.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS fanned_out_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_posts_not_fanned_out ON posts (user_id, created_at DESC, id DESC) WHERE fanned_out_at IS NULL;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS user_blocks (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,    -- user who blocked
+    blocked_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id);
+
+CREATE TABLE IF NOT EXISTS user_mutes (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,    -- user who muted
+    muted_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, muted_id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_created_at;
+DROP TABLE IF EXISTS tag_denylist;
+ALTER TABLE users DROP COLUMN IF EXISTS is_moderator;
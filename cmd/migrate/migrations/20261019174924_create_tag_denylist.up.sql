@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_moderator BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Tags suppressed by moderators from the trending tags, stored lowercase
+CREATE TABLE IF NOT EXISTS tag_denylist (
+    tag TEXT PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- Trending tags scan the posts of the last weeks
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
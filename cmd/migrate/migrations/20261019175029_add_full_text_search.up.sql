@@ -0,0 +1,50 @@
+-- Full-text search vectors, maintained by triggers
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS search_vector tsvector;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION posts_search_vector_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('english', coalesce(NEW.title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(NEW.content, '')), 'B') ||
+        setweight(to_tsvector('simple', coalesce(array_to_string(NEW.tags, ' '), '')), 'C');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER posts_search_vector_update
+    BEFORE INSERT OR UPDATE OF title, content, tags ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_search_vector_update();
+
+CREATE OR REPLACE FUNCTION comments_search_vector_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector := to_tsvector('english', coalesce(NEW.content, ''));
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER comments_search_vector_update
+    BEFORE INSERT OR UPDATE OF content ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_search_vector_update();
+
+CREATE OR REPLACE FUNCTION users_search_vector_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector := to_tsvector('simple', coalesce(NEW.username, ''));
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_search_vector_update
+    BEFORE INSERT OR UPDATE OF username ON users
+    FOR EACH ROW EXECUTE FUNCTION users_search_vector_update();
+
+-- Fill the vectors of the existing rows through the triggers
+UPDATE posts SET title = title;
+UPDATE comments SET content = content;
+UPDATE users SET username = username;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_comments_search_vector ON comments USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_users_search_vector ON users USING gin (search_vector);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS content_filters;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS language;
@@ -0,0 +1,15 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS language varchar(8);
+
+CREATE TABLE IF NOT EXISTS content_filters (
+    id bigserial PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind varchar(16) NOT NULL CHECK (kind IN ('word', 'tag', 'language')),
+    value varchar(255) NOT NULL,
+    whole_word BOOLEAN NOT NULL DEFAULT FALSE,
+    is_regex BOOLEAN NOT NULL DEFAULT FALSE,
+    pattern text NOT NULL DEFAULT '',  -- word filters: regex matched case-insensitively against title and content
+    expires_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    UNIQUE (user_id, kind, value)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_public_created_at_id;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS visibility;
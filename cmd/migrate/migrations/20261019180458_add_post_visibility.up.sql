@@ -0,0 +1,6 @@
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS visibility varchar(16) NOT NULL DEFAULT 'public'
+        CHECK (visibility IN ('public', 'unlisted', 'followers', 'private'));
+
+-- Explore and tag timelines only list public posts
+CREATE INDEX IF NOT EXISTS idx_posts_public_created_at_id ON posts (created_at DESC, id DESC) WHERE visibility = 'public';
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_unpublished_user_id;
+DROP INDEX IF EXISTS idx_posts_scheduled_publish_at;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS publish_at,
+    DROP COLUMN IF EXISTS status;
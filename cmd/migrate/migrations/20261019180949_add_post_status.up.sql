@@ -0,0 +1,9 @@
+-- Drafts and scheduled posts are published later, created_at is then set to the publication
+-- time so that they are ordered as new posts in the feeds.
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS status varchar(16) NOT NULL DEFAULT 'published'
+        CHECK (status IN ('draft', 'scheduled', 'published')),
+    ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled_publish_at ON posts (publish_at) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_posts_unpublished_user_id ON posts (user_id) WHERE status <> 'published';
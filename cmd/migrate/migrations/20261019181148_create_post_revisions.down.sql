@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS posts_updated_at_update ON posts;
+DROP FUNCTION IF EXISTS posts_updated_at_update();
+
+ALTER TABLE posts DROP COLUMN IF EXISTS edited_at;
+
+DROP TABLE IF EXISTS post_revisions;
@@ -0,0 +1,41 @@
+-- Every version of the title, content and tags of a post, the first one being the post as created
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id bigserial PRIMARY KEY,
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    title text NOT NULL,
+    content text NOT NULL,
+    tags varchar(100)[] NOT NULL DEFAULT '{}',
+    editor_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    UNIQUE (post_id, revision)
+);
+
+INSERT INTO post_revisions (post_id, revision, title, content, tags, editor_id, created_at)
+SELECT id, 1, title, content, COALESCE(tags, '{}'), user_id, created_at
+FROM posts
+ON CONFLICT (post_id, revision) DO NOTHING;
+
+-- edited_at is set when a published post is edited, drafts are edited freely
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS edited_at timestamp(0) with time zone;
+
+CREATE OR REPLACE FUNCTION posts_updated_at_update() RETURNS trigger AS $$
+BEGIN
+    IF NEW IS DISTINCT FROM OLD THEN
+        NEW.updated_at := NOW();
+    END IF;
+    IF OLD.status = 'published' AND (
+        NEW.title IS DISTINCT FROM OLD.title OR
+        NEW.content IS DISTINCT FROM OLD.content OR
+        NEW.tags IS DISTINCT FROM OLD.tags
+    ) THEN
+        NEW.edited_at := NOW();
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER posts_updated_at_update
+    BEFORE UPDATE ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_updated_at_update();
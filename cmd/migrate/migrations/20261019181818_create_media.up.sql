@@ -0,0 +1,19 @@
+-- Uploaded files. They are attached to a post once it is created or updated with them, files
+-- left unattached are removed by the media cleaner.
+CREATE TABLE IF NOT EXISTS media (
+    id bigserial PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT REFERENCES posts(id) ON DELETE SET NULL,
+    position INT NOT NULL DEFAULT 0,
+    storage_key text NOT NULL,
+    thumbnail_key text NOT NULL,
+    mime_type varchar(100) NOT NULL,
+    size BIGINT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    alt_text varchar(1000) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_post_id ON media (post_id, position) WHERE post_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_media_unattached ON media (created_at) WHERE post_id IS NULL;
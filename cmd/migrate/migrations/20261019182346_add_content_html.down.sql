@@ -0,0 +1,18 @@
+CREATE OR REPLACE FUNCTION posts_updated_at_update() RETURNS trigger AS $$
+BEGIN
+    IF NEW IS DISTINCT FROM OLD THEN
+        NEW.updated_at := NOW();
+    END IF;
+    IF OLD.status = 'published' AND (
+        NEW.title IS DISTINCT FROM OLD.title OR
+        NEW.content IS DISTINCT FROM OLD.content OR
+        NEW.tags IS DISTINCT FROM OLD.tags
+    ) THEN
+        NEW.edited_at := NOW();
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS content_html;
+ALTER TABLE posts DROP COLUMN IF EXISTS content_html;
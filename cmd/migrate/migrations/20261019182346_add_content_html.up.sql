@@ -0,0 +1,25 @@
+-- The sanitized HTML rendering of the markdown content, written along with it. Existing rows
+-- are rendered by cmd/render.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html text NOT NULL DEFAULT '';
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS content_html text NOT NULL DEFAULT '';
+
+-- Rendering the content again is not an update of the post
+CREATE OR REPLACE FUNCTION posts_updated_at_update() RETURNS trigger AS $$
+DECLARE
+    unrendered posts;
+BEGIN
+    unrendered := NEW;
+    unrendered.content_html := OLD.content_html;
+    IF unrendered IS DISTINCT FROM OLD THEN
+        NEW.updated_at := NOW();
+    END IF;
+    IF OLD.status = 'published' AND (
+        NEW.title IS DISTINCT FROM OLD.title OR
+        NEW.content IS DISTINCT FROM OLD.content OR
+        NEW.tags IS DISTINCT FROM OLD.tags
+    ) THEN
+        NEW.edited_at := NOW();
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
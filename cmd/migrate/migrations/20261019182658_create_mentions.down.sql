@@ -0,0 +1,4 @@
+-- Enum values cannot be dropped, 'mention' stays in notification_type unused
+DELETE FROM notifications WHERE type = 'mention';
+
+DROP TABLE IF EXISTS mentions;
@@ -0,0 +1,15 @@
+-- Users mentioned by a post, or by a comment when comment_id is set, in order of appearance
+CREATE TABLE IF NOT EXISTS mentions (
+    id bigserial PRIMARY KEY,
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INT NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mentions_post_id ON mentions (post_id, position) WHERE comment_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_mentions_comment_id ON mentions (comment_id, position) WHERE comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions (user_id);
+
+ALTER TYPE notification_type ADD VALUE IF NOT EXISTS 'mention';
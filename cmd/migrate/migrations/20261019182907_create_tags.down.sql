@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS posts_tags_count_update ON posts;
+DROP FUNCTION IF EXISTS posts_tags_count_update();
+
+DROP TABLE IF EXISTS tags;
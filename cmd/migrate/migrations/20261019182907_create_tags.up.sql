@@ -0,0 +1,51 @@
+-- The tags of the published public posts, with the number of posts carrying them, for tag
+-- autocompletion. Counts are kept up to date by the trigger below.
+CREATE TABLE IF NOT EXISTS tags (
+    name varchar(100) PRIMARY KEY,
+    posts_count INT NOT NULL DEFAULT 0,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- Prefix searches on the name
+CREATE INDEX IF NOT EXISTS idx_tags_name_prefix ON tags (name varchar_pattern_ops) WHERE posts_count > 0;
+
+CREATE OR REPLACE FUNCTION posts_tags_count_update() RETURNS trigger AS $$
+DECLARE
+    old_tags varchar(100)[] := '{}';
+    new_tags varchar(100)[] := '{}';
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        IF OLD.status = 'published' AND OLD.visibility = 'public' AND OLD.deleted_at IS NULL THEN
+            old_tags := COALESCE(OLD.tags, '{}');
+        END IF;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        IF NEW.status = 'published' AND NEW.visibility = 'public' AND NEW.deleted_at IS NULL THEN
+            new_tags := COALESCE(NEW.tags, '{}');
+        END IF;
+    END IF;
+
+    UPDATE tags
+    SET posts_count = posts_count - 1, updated_at = NOW()
+    WHERE name IN (SELECT unnest(old_tags) EXCEPT SELECT unnest(new_tags));
+
+    INSERT INTO tags (name, posts_count)
+    SELECT t.name, 1
+    FROM (SELECT unnest(new_tags) EXCEPT SELECT unnest(old_tags)) AS t(name)
+    ON CONFLICT (name) DO UPDATE SET posts_count = tags.posts_count + 1, updated_at = NOW();
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER posts_tags_count_update
+    AFTER INSERT OR DELETE OR UPDATE OF tags, status, visibility, deleted_at ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_tags_count_update();
+
+INSERT INTO tags (name, posts_count)
+SELECT t.name, COUNT(DISTINCT p.id)
+FROM posts p
+CROSS JOIN LATERAL unnest(p.tags) AS t(name)
+WHERE p.status = 'published' AND p.visibility = 'public' AND p.deleted_at IS NULL
+GROUP BY t.name
+ON CONFLICT (name) DO UPDATE SET posts_count = EXCLUDED.posts_count;
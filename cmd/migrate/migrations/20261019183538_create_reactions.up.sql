@@ -0,0 +1,16 @@
+-- Reactions of users to posts and comments, one per user and post or comment. The types are
+-- configured by the API (REACTION_TYPES).
+CREATE TABLE IF NOT EXISTS reactions (
+    id bigserial PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    type varchar(32) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_post_user ON reactions (post_id, user_id) WHERE post_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_comment_user ON reactions (comment_id, user_id) WHERE comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_reactions_user_id ON reactions (user_id);
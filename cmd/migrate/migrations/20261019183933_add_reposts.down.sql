@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_posts_quote_of_id;
+DROP INDEX IF EXISTS idx_posts_repost_of_id;
+DROP INDEX IF EXISTS idx_posts_user_id_repost_of_id;
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_repost_or_quote;
+
+-- Reposts have no content of their own
+DELETE FROM posts WHERE repost_of_id IS NOT NULL;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS quote_of_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS repost_of_id;
@@ -0,0 +1,12 @@
+-- Reposts share the original post with the followers of the reposter: they are posts without
+-- content of their own, fanned out like any other post. Quote posts are regular posts embedding
+-- the original. Reposts go with their original, quotes outlive it.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS repost_of_id BIGINT REFERENCES posts(id) ON DELETE CASCADE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS quote_of_id BIGINT REFERENCES posts(id) ON DELETE SET NULL;
+
+ALTER TABLE posts ADD CONSTRAINT posts_repost_or_quote CHECK (repost_of_id IS NULL OR quote_of_id IS NULL);
+
+-- A post is reposted once per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_id_repost_of_id ON posts (user_id, repost_of_id) WHERE repost_of_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_repost_of_id ON posts (repost_of_id) WHERE repost_of_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_quote_of_id ON posts (quote_of_id) WHERE quote_of_id IS NOT NULL;
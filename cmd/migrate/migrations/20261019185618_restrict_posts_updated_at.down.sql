@@ -0,0 +1,25 @@
+CREATE OR REPLACE FUNCTION posts_updated_at_update() RETURNS trigger AS $$
+DECLARE
+    unrendered posts;
+BEGIN
+    unrendered := NEW;
+    unrendered.content_html := OLD.content_html;
+    IF unrendered IS DISTINCT FROM OLD THEN
+        NEW.updated_at := NOW();
+    END IF;
+    IF OLD.status = 'published' AND (
+        NEW.title IS DISTINCT FROM OLD.title OR
+        NEW.content IS DISTINCT FROM OLD.content OR
+        NEW.tags IS DISTINCT FROM OLD.tags
+    ) THEN
+        NEW.edited_at := NOW();
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_updated_at_update ON posts;
+
+CREATE TRIGGER posts_updated_at_update
+    BEFORE UPDATE ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_updated_at_update();
@@ -0,0 +1,22 @@
+-- Only edits of the title, content or tags update a post: rendering its content, fanning it
+-- out, trashing or restoring it leave updated_at as is
+CREATE OR REPLACE FUNCTION posts_updated_at_update() RETURNS trigger AS $$
+BEGIN
+    IF NEW.title IS DISTINCT FROM OLD.title OR
+        NEW.content IS DISTINCT FROM OLD.content OR
+        NEW.tags IS DISTINCT FROM OLD.tags
+    THEN
+        NEW.updated_at := NOW();
+        IF OLD.status = 'published' THEN
+            NEW.edited_at := NOW();
+        END IF;
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_updated_at_update ON posts;
+
+CREATE TRIGGER posts_updated_at_update
+    BEFORE UPDATE OF title, content, tags ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_updated_at_update();
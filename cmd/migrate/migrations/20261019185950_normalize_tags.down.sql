@@ -0,0 +1 @@
+-- The forms the tags were typed in are lost, they stay normalized
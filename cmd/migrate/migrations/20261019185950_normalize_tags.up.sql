@@ -0,0 +1,52 @@
+-- Tags are stored in the form of utils.NormalizeTag: trimmed, without their leading #, case
+-- folded and NFC normalized. Older posts, deny-listed tags and muted tags kept the form they
+-- were typed in. lower() folds the case like cases.Fold but for a few characters, the ones
+-- found in tags are replaced. It depends on the locale of the database, which is UTF-8.
+CREATE FUNCTION pg_temp.normalize_tag(tag text) RETURNS text AS $$
+    SELECT replace(replace(replace(
+        normalize(lower(regexp_replace(btrim(tag), '^#', '')), NFC),
+        'ß', 'ss'), 'ς', 'σ'), 'ſ', 's')
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Normalized tags, in order, without duplicates nor invalid tags
+CREATE FUNCTION pg_temp.normalize_tags(tags varchar(100)[]) RETURNS varchar(100)[] AS $$
+    SELECT COALESCE(array_agg(t.tag ORDER BY t.ord), '{}')
+    FROM (
+        SELECT DISTINCT ON (pg_temp.normalize_tag(u.tag)) pg_temp.normalize_tag(u.tag) AS tag, u.ord
+        FROM unnest(tags) WITH ORDINALITY AS u(tag, ord)
+        ORDER BY pg_temp.normalize_tag(u.tag), u.ord
+    ) t
+    WHERE t.tag <> '' AND char_length(t.tag) <= 100
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Normalizing the tags is not an edit of the posts
+ALTER TABLE posts DISABLE TRIGGER posts_updated_at_update;
+
+UPDATE posts
+SET tags = pg_temp.normalize_tags(tags)
+WHERE tags IS DISTINCT FROM pg_temp.normalize_tags(tags);
+
+ALTER TABLE posts ENABLE TRIGGER posts_updated_at_update;
+
+-- Tags denied or muted in several forms are kept once, the oldest
+DELETE FROM tag_denylist d
+USING tag_denylist o
+WHERE pg_temp.normalize_tag(o.tag) = pg_temp.normalize_tag(d.tag)
+    AND (o.created_at, o.tag) < (d.created_at, d.tag);
+
+UPDATE tag_denylist
+SET tag = pg_temp.normalize_tag(tag)
+WHERE tag <> pg_temp.normalize_tag(tag);
+
+DELETE FROM content_filters
+WHERE kind = 'tag' AND (pg_temp.normalize_tag(value) = '' OR char_length(pg_temp.normalize_tag(value)) > 100);
+
+DELETE FROM content_filters f
+USING content_filters o
+WHERE f.kind = 'tag' AND o.kind = 'tag' AND o.user_id = f.user_id
+    AND pg_temp.normalize_tag(o.value) = pg_temp.normalize_tag(f.value)
+    AND (o.created_at, o.id) < (f.created_at, f.id);
+
+UPDATE content_filters
+SET value = pg_temp.normalize_tag(value)
+WHERE kind = 'tag' AND value <> pg_temp.normalize_tag(value);
@@ -0,0 +1,9 @@
+-- The handlers that handled an outbox event, skipped when the relay retries the event after
+-- another handler failed
+CREATE TABLE IF NOT EXISTS outbox_event_handlers (
+    event_id BIGINT NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+    handler TEXT NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (event_id, handler)
+);
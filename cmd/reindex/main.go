@@ -0,0 +1,144 @@
+// Command reindex rebuilds the search index from the store.
+//
+// With the Postgres backend the search vectors are recomputed in place. With the Bleve backend a
+// new index is built next to the current one and swapped in once complete; run it while the API
+// is stopped, as the index is locked by the server and events published meanwhile would be lost.
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/env"
+	log "github.com/mafi020/social/internal/logger"
+	"github.com/mafi020/social/internal/search"
+	"github.com/mafi020/social/internal/store"
+)
+
+const batchSize = 500
+
+func main() {
+	// The environment may also come from the process, the .env file is optional here
+	_ = godotenv.Load()
+
+	logger := log.New()
+	defer logger.Sync()
+
+	db, err := db.New(
+		env.GetEnvOrPanic("PSQL_URL"),
+		env.GetEnvAsIntOrPanic("PSQL_MAX_OPEN_CONNS"),
+		env.GetEnvAsIntOrPanic("PSQL_MAX_IDLE_CONNS"),
+		env.GetEnvOrPanic("PSQL_MAX_IDLE_TIME"),
+	)
+	if err != nil {
+		logger.Fatalw("Failed to connect to Postgres DB", "error", err)
+	}
+	defer db.Close()
+
+	store := store.NewPostgresStorage(db)
+	ctx := context.Background()
+	start := time.Now()
+
+	switch backend := env.GetEnvOrDefault("SEARCH_BACKEND", search.BackendPostgres); backend {
+	case search.BackendPostgres:
+		if err := store.Search.Reindex(ctx); err != nil {
+			logger.Fatalw("Failed to recompute the search vectors", "error", err)
+		}
+
+	case search.BackendBleve:
+		path := env.GetEnvOrDefault("SEARCH_BLEVE_PATH", "data/search.bleve")
+		if err := rebuildBleve(ctx, store, path); err != nil {
+			logger.Fatalw("Failed to rebuild the search index", "path", path, "error", err)
+		}
+
+	default:
+		logger.Fatalw("Unknown search backend", "backend", backend)
+	}
+
+	logger.Infow("Search index rebuilt", "duration", time.Since(start).String())
+}
+
+func rebuildBleve(ctx context.Context, store store.Storage, path string) error {
+	tmpPath := path + ".reindex"
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+
+	index, err := search.OpenBleve(tmpPath)
+	if err != nil {
+		return err
+	}
+	bleveIndex := search.NewBleveIndex(index, store.Posts, store.Comments, store.Blocks, store.Filters, nil)
+
+	if err := indexAll(ctx, store, bleveIndex); err != nil {
+		bleveIndex.Close()
+		return err
+	}
+	if err := bleveIndex.Close(); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func indexAll(ctx context.Context, store store.Storage, index *search.BleveIndex) error {
+	var afterID int64
+	for {
+		posts, err := store.Posts.ListAfterID(ctx, afterID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(posts) == 0 {
+			break
+		}
+
+		docs := make([]dto.SearchDocument, 0, len(posts))
+		for _, p := range posts {
+			// Drafts and scheduled posts are indexed when they are published
+			if p.Status != dto.PostStatusPublished {
+				continue
+			}
+			// Reposts have no content of their own
+			if p.RepostOfID != nil {
+				continue
+			}
+			docs = append(docs, search.PostDocument(p))
+		}
+		if err := index.IndexBatch(docs); err != nil {
+			return err
+		}
+		afterID = posts[len(posts)-1].ID
+	}
+
+	afterID = 0
+	for {
+		comments, err := store.Comments.ListAfterID(ctx, afterID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(comments) == 0 {
+			break
+		}
+
+		docs := make([]dto.SearchDocument, 0, len(comments))
+		for _, c := range comments {
+			if c.PostStatus != dto.PostStatusPublished {
+				continue
+			}
+			docs = append(docs, search.CommentDocument(c.Comment, c.PostAuthorID))
+		}
+		if err := index.IndexBatch(docs); err != nil {
+			return err
+		}
+		afterID = comments[len(comments)-1].ID
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+// Command render renders the markdown content of every post and comment to HTML again, the
+// trash included. Run it after the migration adding the HTML column, and whenever the rendering
+// changes. Rows whose HTML is unchanged are not written.
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/env"
+	log "github.com/mafi020/social/internal/logger"
+	"github.com/mafi020/social/internal/markdown"
+	"github.com/mafi020/social/internal/store"
+)
+
+const batchSize = 500
+
+func main() {
+	// The environment may also come from the process, the .env file is optional here
+	_ = godotenv.Load()
+
+	logger := log.New()
+	defer logger.Sync()
+
+	db, err := db.New(
+		env.GetEnvOrPanic("PSQL_URL"),
+		env.GetEnvAsIntOrPanic("PSQL_MAX_OPEN_CONNS"),
+		env.GetEnvAsIntOrPanic("PSQL_MAX_IDLE_CONNS"),
+		env.GetEnvOrPanic("PSQL_MAX_IDLE_TIME"),
+	)
+	if err != nil {
+		logger.Fatalw("Failed to connect to Postgres DB", "error", err)
+	}
+	defer db.Close()
+
+	store := store.NewPostgresStorage(db)
+	ctx := context.Background()
+	start := time.Now()
+
+	posts, err := renderPosts(ctx, store)
+	if err != nil {
+		logger.Fatalw("Failed to render the posts", "error", err)
+	}
+
+	comments, err := renderComments(ctx, store)
+	if err != nil {
+		logger.Fatalw("Failed to render the comments", "error", err)
+	}
+
+	logger.Infow("Content rendered", "posts", posts, "comments", comments, "duration", time.Since(start).String())
+}
+
+// renderPosts returns the number of posts whose HTML changed.
+func renderPosts(ctx context.Context, store store.Storage) (int, error) {
+	var afterID int64
+	var rendered int
+	for {
+		posts, err := store.Posts.ListContentAfterID(ctx, afterID, batchSize)
+		if err != nil {
+			return rendered, err
+		}
+		if len(posts) == 0 {
+			return rendered, nil
+		}
+
+		for _, p := range posts {
+			html := markdown.Render(p.Content)
+			if html == p.ContentHTML {
+				continue
+			}
+			if err := store.Posts.SetContentHTML(ctx, p.ID, html); err != nil {
+				return rendered, err
+			}
+			rendered++
+		}
+		afterID = posts[len(posts)-1].ID
+	}
+}
+
+// renderComments returns the number of comments whose HTML changed.
+func renderComments(ctx context.Context, store store.Storage) (int, error) {
+	var afterID int64
+	var rendered int
+	for {
+		comments, err := store.Comments.ListContentAfterID(ctx, afterID, batchSize)
+		if err != nil {
+			return rendered, err
+		}
+		if len(comments) == 0 {
+			return rendered, nil
+		}
+
+		for _, c := range comments {
+			html := markdown.Render(c.Content)
+			if html == c.ContentHTML {
+				continue
+			}
+			if err := store.Comments.SetContentHTML(ctx, c.ID, html); err != nil {
+				return rendered, err
+			}
+			rendered++
+		}
+		afterID = comments[len(comments)-1].ID
+	}
+}
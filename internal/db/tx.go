@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+type txKey struct{}
+
+type txState struct {
+	tx *sql.Tx
+
+	mu          sync.Mutex
+	afterCommit []func()
+}
+
+// Executor is implemented by both *sql.DB and *sql.Tx.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// WithTx runs fn in a transaction carried by the context. Stores resolving their connection
+// with Conn take part in it. The transaction is rolled back if fn returns an error, and
+// callbacks registered with AfterCommit run once it is committed.
+func WithTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	// Nested calls join the outer transaction.
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	state := &txState{tx: tx}
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, f := range state.afterCommit {
+		f()
+	}
+	return nil
+}
+
+// Conn returns the transaction carried by the context, or db when there is none.
+func Conn(ctx context.Context, db *sql.DB) Executor {
+	if state := txFromContext(ctx); state != nil {
+		return state.tx
+	}
+	return db
+}
+
+// InTx reports whether the context carries a transaction.
+func InTx(ctx context.Context) bool {
+	return txFromContext(ctx) != nil
+}
+
+// Detach returns a copy of the context without its transaction, for work running once the
+// transaction is over: callbacks registered with AfterCommit get a committed transaction
+// otherwise, which fails every statement with sql.ErrTxDone.
+func Detach(ctx context.Context) context.Context {
+	if txFromContext(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, txKey{}, (*txState)(nil))
+}
+
+// txFromContext returns the transaction carried by the context, nil when there is none or it
+// was detached.
+func txFromContext(ctx context.Context) *txState {
+	state, _ := ctx.Value(txKey{}).(*txState)
+	return state
+}
+
+// AfterCommit defers f until the transaction carried by the context commits, f is dropped
+// on rollback. Without a transaction f runs immediately.
+func AfterCommit(ctx context.Context, f func()) {
+	state := txFromContext(ctx)
+	if state == nil {
+		f()
+		return
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.afterCommit = append(state.afterCommit, f)
+}
@@ -1,16 +1,30 @@
 package dto
 
+import "time"
+
 type Comment struct {
-	ID        int64       `json:"id"`
-	PostID    int64       `json:"post_id"`
-	UserID    int64       `json:"user_id"`
-	Content   string      `json:"content"`
-	User      CommentUser `json:"user"`
-	CreatedAt string      `json:"created_at"`
-	UpdatedAt string      `json:"updated_at"`
+	ID             int64          `json:"id"`
+	PostID         int64          `json:"post_id"`
+	UserID         int64          `json:"user_id"`
+	Content        string         `json:"content"`                   // markdown
+	ContentHTML    string         `json:"content_html"`              // sanitized rendering of the content
+	Mentions       []Mention      `json:"mentions,omitempty"`        // in order of appearance
+	Reactions      map[string]int `json:"reactions,omitempty"`       // by type
+	ViewerReaction *string        `json:"viewer_reaction,omitempty"` // type of the reaction of the viewer
+	User           CommentUser    `json:"user"`
+	CreatedAt      string         `json:"created_at"`
+	UpdatedAt      string         `json:"updated_at"`
+	DeletedAt      *time.Time     `json:"deleted_at,omitempty"` // comments in the trash
 }
 
 type CommentUser struct {
 	ID       int64  `json:"id"`
 	UserName string `json:"username"`
 }
+
+type CommentWithPostAuthor struct {
+	Comment
+	PostAuthorID   int64  `json:"post_author_id"`
+	PostVisibility string `json:"post_visibility"`
+	PostStatus     string `json:"post_status"`
+}
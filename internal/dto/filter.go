@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+const (
+	FilterKindWord     = "word"
+	FilterKindTag      = "tag"
+	FilterKindLanguage = "language"
+)
+
+// ContentFilter hides content from a user without unfollowing anyone: posts containing a
+// muted word or carrying a muted tag, and posts written in a language other than their
+// preferred ones. Posts without a language are always shown.
+type ContentFilter struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Kind      string     `json:"kind"`
+	Value     string     `json:"value"`
+	WholeWord bool       `json:"whole_word"` // word filters: match whole words only
+	IsRegex   bool       `json:"regex"`      // word filters: Value is a regular expression
+	ExpiresAt *time.Time `json:"expires_at"` // nil for filters that never expire
+	CreatedAt string     `json:"created_at"`
+
+	// Regular expression matched case-insensitively by word filters, derived from Value.
+	Pattern string `json:"-"`
+}
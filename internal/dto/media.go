@@ -0,0 +1,21 @@
+package dto
+
+// Media is an uploaded file, attached to at most one post.
+type Media struct {
+	ID           int64  `json:"id"`
+	UserID       int64  `json:"user_id"`
+	PostID       *int64 `json:"post_id,omitempty"`
+	Position     int    `json:"position"` // order among the attachments of the post
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	MimeType     string `json:"mime_type"`
+	Size         int64  `json:"size"` // bytes
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	AltText      string `json:"alt_text"`
+	CreatedAt    string `json:"created_at"`
+
+	// Blob keys, see interfaces.BlobStore
+	StorageKey   string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
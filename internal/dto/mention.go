@@ -0,0 +1,9 @@
+package dto
+
+// Mention is a user mentioned with @username in the content of a post or comment.
+type Mention struct {
+	UserID    int64  `json:"user_id"`
+	UserName  string `json:"username"`
+	PostID    int64  `json:"-"`
+	CommentID *int64 `json:"-"` // mentions of comments
+}
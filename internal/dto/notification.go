@@ -0,0 +1,26 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	NotificationFollow             = "follow"
+	NotificationComment            = "comment"
+	NotificationMention            = "mention"
+	NotificationInvitationAccepted = "invitation_accepted"
+)
+
+type Notification struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	ActorID   *int64          `json:"actor_id"`
+	Actor     *CommentUser    `json:"actor,omitempty"` // nil when the actor is not a registered user
+	Type      string          `json:"type"`
+	PostID    *int64          `json:"post_id,omitempty"`
+	CommentID *int64          `json:"comment_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt string          `json:"created_at"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+import "encoding/json"
+
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	CreatedAt string          `json:"created_at"`
+}
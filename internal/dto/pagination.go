@@ -1,8 +1,16 @@
 package dto
 
+import "time"
+
 type Pagination struct {
 	Page       int `json:"page"`
 	Limit      int `json:"limit"`
 	TotalCount int `json:"total_count"`
 	TotalPages int `json:"total_pages"`
 }
+
+// FeedCursor is the keyset position of a post in a feed, which is ordered by (created_at, id).
+type FeedCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
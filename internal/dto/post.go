@@ -1,18 +1,84 @@
 package dto
 
+import "time"
+
+// Post visibility levels
+const (
+	VisibilityPublic    = "public"    // listed everywhere
+	VisibilityUnlisted  = "unlisted"  // readable by anyone with the link, not listed in explore, tags and search
+	VisibilityFollowers = "followers" // readable by the followers of the author only
+	VisibilityPrivate   = "private"   // readable by the author only
+)
+
+// Post statuses, only published posts are shown to other users
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled" // published by the scheduler at PublishAt
+	PostStatusPublished = "published"
+)
+
 type Post struct {
-	ID        int64       `json:"id"`
-	Content   string      `json:"content"`
-	Title     string      `json:"title"`
-	UserID    int64       `json:"user_id"`
-	Tags      []string    `json:"tags"`
-	Comments  []Comment   `json:"comments"`
-	CreatedAt string      `json:"created_at"`
-	UpdatedAt string      `json:"updated_at"`
-	User      CommentUser `json:"user"`
+	ID             int64          `json:"id"`
+	Content        string         `json:"content"`      // markdown
+	ContentHTML    string         `json:"content_html"` // sanitized rendering of the content
+	Title          string         `json:"title"`
+	UserID         int64          `json:"user_id"`
+	Tags           []string       `json:"tags"`
+	Language       string         `json:"language,omitempty"` // ISO 639-1 code, set by the author
+	Visibility     string         `json:"visibility"`
+	Status         string         `json:"status"`
+	PublishAt      *time.Time     `json:"publish_at,omitempty"` // scheduled posts
+	Comments       []Comment      `json:"comments"`
+	Attachments    []Media        `json:"attachments,omitempty"`     // ordered
+	Mentions       []Mention      `json:"mentions,omitempty"`        // in order of appearance
+	Reactions      map[string]int `json:"reactions,omitempty"`       // by type
+	ViewerReaction *string        `json:"viewer_reaction,omitempty"` // type of the reaction of the viewer
+	RepostOfID     *int64         `json:"repost_of_id,omitempty"`    // reposts have no content of their own
+	RepostOf       *Post          `json:"repost_of,omitempty"`       // the original, unless the viewer may no longer read it
+	QuoteOfID      *int64         `json:"quote_of_id,omitempty"`     // quote posts
+	QuoteOf        *Post          `json:"quote_of,omitempty"`        // the quoted post, unless the viewer may no longer read it
+	CreatedAt      string         `json:"created_at"`
+	UpdatedAt      string         `json:"updated_at"`
+	EditedAt       *time.Time     `json:"edited_at,omitempty"`  // last edit of the published post, see PostRevision
+	DeletedAt      *time.Time     `json:"deleted_at,omitempty"` // posts in the trash
+	User           CommentUser    `json:"user"`
 }
 
 type Feed struct {
 	Post
-	CommentsCount int64 `json:"comments_count"`
+	CommentsCount int64      `json:"comments_count"`
+	Score         *FeedScore `json:"score,omitempty"` // set by the top feed in explain mode
+
+	// Expansions, see FeedIncludes
+	Author *UserProfile `json:"author,omitempty"`
+}
+
+// FeedIncludes are the optional expansions of a page of posts (?include=). Each one is loaded
+// for the whole page at once.
+type FeedIncludes struct {
+	CommentsPreview      bool // the latest comments of each post, in Comments
+	CommentsPreviewLimit int
+	Author               bool // the profile of the author of each post, in Author
+	Reactions            bool // the reactions to each post and previewed comment, in Reactions and ViewerReaction
+}
+
+// FeedScore is the ranking score of a post in the top feed, broken down into the weighted
+// contribution of each signal.
+type FeedScore struct {
+	Total     float64 `json:"total"`
+	Recency   float64 `json:"recency"`
+	Comments  float64 `json:"comments"`
+	Reactions float64 `json:"reactions"`
+	Affinity  float64 `json:"affinity"`
+}
+
+// FeedRanking holds the weights of the top feed signals.
+type FeedRanking struct {
+	RecencyWeight   float64
+	CommentsWeight  float64
+	ReactionsWeight float64
+	AffinityWeight  float64
+
+	HalfLifeHours float64 // age at which the recency signal is halved
+	WindowHours   int     // only posts younger than this are ranked
 }
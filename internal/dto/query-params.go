@@ -6,4 +6,31 @@ type FeedQueryParams struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
 	Tags   []string `json:"tags" validate:"max=5"`
 	Search string   `json:"search" validate:"max=100"`
+	Sort   string   `json:"sort"` // latest (default) or top
+	Tag    string   `json:"-"`    // per-tag timelines: only posts carrying the tag
+
+	// Keyset pagination, Page is ignored when Cursor is set. Posts older than the cursor are
+	// returned, or newer ones when Backward is set.
+	Cursor   *FeedCursor `json:"-"`
+	Backward bool        `json:"-"`
+}
+
+// Notifications Query Params
+type NotificationQueryParams struct {
+	Limit      int   `json:"limit"`
+	Cursor     int64 `json:"cursor"` // id of the last notification already seen, 0 for the first page
+	UnreadOnly bool  `json:"unread_only"`
+}
+
+// Reactions Query Params
+type ReactionQueryParams struct {
+	Limit  int    `json:"limit"`
+	Cursor int64  `json:"cursor"` // id of the last reaction already seen, 0 for the first page
+	Type   string `json:"type"`   // only the reactions of the type when set
+}
+
+// Webhook Deliveries Query Params
+type WebhookDeliveryQueryParams struct {
+	Limit  int   `json:"limit"`
+	Cursor int64 `json:"cursor"` // id of the last delivery already seen, 0 for the first page
 }
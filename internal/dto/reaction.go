@@ -0,0 +1,29 @@
+package dto
+
+// What reactions are made to
+const (
+	ReactionTargetPost    = "post"
+	ReactionTargetComment = "comment"
+)
+
+// Reaction is the reaction of a user to a post or comment, users react once to each.
+type Reaction struct {
+	ID        int64       `json:"id"`
+	Type      string      `json:"type"`
+	User      CommentUser `json:"user"`
+	CreatedAt string      `json:"created_at"`
+}
+
+// ReactionCount is the number of reactions of a type to a post or comment.
+type ReactionCount struct {
+	TargetID      int64
+	Type          string
+	Count         int
+	ViewerReacted bool // the viewer is among them
+}
+
+// ReactionSummary sums up the reactions to a post or comment.
+type ReactionSummary struct {
+	Reactions      map[string]int `json:"reactions"` // by type
+	ViewerReaction *string        `json:"viewer_reaction"`
+}
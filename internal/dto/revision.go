@@ -0,0 +1,37 @@
+package dto
+
+// PostRevision is a version of the title, content and tags of a post. Revisions are numbered
+// from 1, the post as created.
+type PostRevision struct {
+	PostID    int64    `json:"post_id"`
+	Revision  int      `json:"revision"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	EditorID  *int64   `json:"editor_id"` // nil once the editor deleted their account
+	CreatedAt string   `json:"created_at"`
+}
+
+// Diff operations
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffLine is a line of a diff, kept, inserted or deleted.
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// PostRevisionDiff is the difference between two revisions of a post, line by line.
+type PostRevisionDiff struct {
+	PostID      int64      `json:"post_id"`
+	From        int        `json:"from"`
+	To          int        `json:"to"`
+	Title       []DiffLine `json:"title"`
+	Content     []DiffLine `json:"content"`
+	TagsAdded   []string   `json:"tags_added"`
+	TagsRemoved []string   `json:"tags_removed"`
+}
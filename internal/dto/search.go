@@ -0,0 +1,40 @@
+package dto
+
+const (
+	SearchTypePosts    = "posts"
+	SearchTypeComments = "comments"
+	SearchTypeUsers    = "users"
+)
+
+// Search Query Params
+type SearchQueryParams struct {
+	Query string `json:"q" validate:"required,max=200"` // websearch_to_tsquery syntax: "phrase", -exclude, or
+	Type  string `json:"type" validate:"oneof=posts comments users"`
+	Limit int    `json:"limit"`
+	Page  int    `json:"page"`
+}
+
+// SearchHit is a search result, only the field matching its type is set.
+type SearchHit struct {
+	Type     string       `json:"type"`
+	ID       int64        `json:"id"`
+	Rank     float64      `json:"rank"`
+	Headline string       `json:"headline,omitempty"` // matching fragments as escaped HTML: the <mark> tags wrapping the terms are its only markup
+	Post     *Post        `json:"post,omitempty"`
+	Comment  *Comment     `json:"comment,omitempty"`
+	User     *CommentUser `json:"user,omitempty"`
+}
+
+// SearchDocument is a post or a comment as stored in a search index.
+type SearchDocument struct {
+	Type         string // SearchTypePosts or SearchTypeComments
+	ID           int64
+	UserID       int64
+	PostID       int64 // comments only
+	PostAuthorID int64 // comments only
+	Title        string
+	Content      string
+	Tags         []string
+	Language     string // posts only
+	Visibility   string // posts only
+}
@@ -0,0 +1,24 @@
+package dto
+
+// Tag is a tag of published public posts, with the number of posts carrying it.
+type Tag struct {
+	Name       string `json:"name"`
+	PostsCount int    `json:"posts_count"`
+}
+
+// TrendingTag compares the use of a tag over a window with its baseline, the average use over
+// an equally long window during the preceding period.
+type TrendingTag struct {
+	Tag           string  `json:"tag"`
+	Posts         int     `json:"posts"`
+	BaselinePosts int     `json:"baseline_posts"` // posts during the whole baseline period
+	Expected      float64 `json:"expected"`       // posts expected over the window from the baseline
+	Score         float64 `json:"score"`
+}
+
+type DeniedTag struct {
+	Tag       string `json:"tag"`
+	Reason    string `json:"reason"`
+	CreatedBy *int64 `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+// Trash lists the deleted posts and comments of a user, they can be restored until they are
+// purged at PurgeAfterDays days old.
+type Trash struct {
+	Posts          []Post    `json:"posts"`
+	Comments       []Comment `json:"comments"`
+	PurgeAfterDays int       `json:"purge_after_days"`
+}
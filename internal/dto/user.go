@@ -1,10 +1,23 @@
 package dto
 
 type User struct {
-	ID        int64  `json:"id"`
-	UserName  string `json:"username"`
-	Email     string `json:"email"`
-	Password  string `json:"-"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID          int64  `json:"id"`
+	UserName    string `json:"username"`
+	Email       string `json:"email"`
+	Password    string `json:"-"`
+	IsModerator bool   `json:"is_moderator"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// UserProfile is the public profile of a user, as seen by the logged in user.
+type UserProfile struct {
+	ID             int64  `json:"id"`
+	UserName       string `json:"username"`
+	IsModerator    bool   `json:"is_moderator"`
+	CreatedAt      string `json:"created_at"`
+	FollowersCount int64  `json:"followers_count"`
+	FollowingCount int64  `json:"following_count"`
+	PostsCount     int64  `json:"posts_count"`
+	IsFollowed     bool   `json:"is_followed"` // the logged in user follows them
 }
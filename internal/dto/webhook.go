@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookSubscription struct {
+	ID         int64    `json:"id"`
+	UserID     int64    `json:"user_id"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"` // only returned when the subscription is created
+	EventTypes []string `json:"event_types"`
+	Active     bool     `json:"active"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscription_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      string          `json:"created_at"`
+
+	// Target of the delivery, loaded for the worker only.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
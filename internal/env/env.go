@@ -23,3 +23,47 @@ func GetEnvAsIntOrPanic(key string) int {
 	}
 	return intVal
 }
+
+func GetEnvOrDefault(key, defaultVal string) string {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return defaultVal
+	}
+	return val
+}
+
+func GetEnvAsBoolOrDefault(key string, defaultVal bool) bool {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return defaultVal
+	}
+	boolVal, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Panicf("Invalid bool value for %s: %v", key, err)
+	}
+	return boolVal
+}
+
+func GetEnvAsIntOrDefault(key string, defaultVal int) int {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return defaultVal
+	}
+	intVal, err := strconv.Atoi(val)
+	if err != nil {
+		log.Panicf("Invalid int value for %s: %v", key, err)
+	}
+	return intVal
+}
+
+func GetEnvAsFloatOrDefault(key string, defaultVal float64) float64 {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return defaultVal
+	}
+	floatVal, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		log.Panicf("Invalid float value for %s: %v", key, err)
+	}
+	return floatVal
+}
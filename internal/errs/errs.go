@@ -6,4 +6,9 @@ var (
 	ErrNotFound       = errors.New("resource not found")
 	ErrDuplicateEntry = errors.New("duplicate entry")
 	ErrUnauthorized   = errors.New("unauthorized access")
+	ErrForbidden      = errors.New("you are not allowed to access this resource")
+	ErrInvalidQuery   = errors.New("invalid search query")
+	ErrInvalidPattern = errors.New("invalid regular expression")
+	ErrComplexPattern = errors.New("regular expression too complex")
+	ErrInvalidMedia   = errors.New("media not found or attached to another post")
 )
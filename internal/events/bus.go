@@ -0,0 +1,193 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mafi020/social/internal/db"
+	"go.uber.org/zap"
+)
+
+// Handler reacts to an event. Errors of synchronous handlers are returned to the publisher,
+// those of asynchronous handlers are logged, unless the relay dispatches the event.
+type Handler func(ctx context.Context, evt Event) error
+
+// Outbox persists events to be dispatched later by a Relay.
+type Outbox interface {
+	Insert(ctx context.Context, name string, payload []byte) error
+}
+
+// subscription is a handler subscribed to an event under a name, unique for the event. The
+// relay records the names of the handlers that handled an event of the outbox.
+type subscription struct {
+	name    string
+	handler Handler
+}
+
+type Bus struct {
+	mu     sync.RWMutex
+	sync   map[string][]subscription
+	async  map[string][]subscription
+	outbox Outbox
+	logger *zap.SugaredLogger
+	wg     sync.WaitGroup
+}
+
+func NewBus(logger *zap.SugaredLogger) *Bus {
+	return &Bus{
+		sync:   make(map[string][]subscription),
+		async:  make(map[string][]subscription),
+		logger: logger,
+	}
+}
+
+// UseOutbox switches the bus to the transactional outbox: Publish stores the event in the
+// transaction carried by the context and a Relay dispatches it once committed.
+func (b *Bus) UseOutbox(o Outbox) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outbox = o
+}
+
+// Subscribe registers a handler, named handler, run in the publisher's goroutine.
+func (b *Bus) Subscribe(name, handler string, h Handler) {
+	b.subscribe(b.sync, name, handler, h)
+}
+
+// SubscribeAsync registers a handler, named handler, run in its own goroutine.
+func (b *Bus) SubscribeAsync(name, handler string, h Handler) {
+	b.subscribe(b.async, name, handler, h)
+}
+
+func (b *Bus) subscribe(subs map[string][]subscription, name, handler string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, s := range append(b.sync[name], b.async[name]...) {
+		if s.name == handler {
+			panic(fmt.Sprintf("events: handler %q already subscribed to %s", handler, name))
+		}
+	}
+	subs[name] = append(subs[name], subscription{name: handler, handler: h})
+}
+
+// Publish hands the event to its subscribers. Inside a transaction (see db.WithTx) subscribers
+// only run once it commits, so they never observe uncommitted writes.
+func (b *Bus) Publish(ctx context.Context, evt Event) error {
+	b.mu.RLock()
+	outbox := b.outbox
+	b.mu.RUnlock()
+
+	if outbox != nil {
+		payload, err := json.Marshal(evt)
+		if err != nil {
+			return err
+		}
+		return outbox.Insert(ctx, evt.Name(), payload)
+	}
+
+	if !db.InTx(ctx) {
+		return b.Dispatch(ctx, evt)
+	}
+
+	// Subscribers write through their own connections, the transaction is over
+	db.AfterCommit(ctx, func() {
+		if err := b.Dispatch(db.Detach(ctx), evt); err != nil {
+			b.logger.Warnw("event handler failed", "event", evt.Name(), "error", err)
+		}
+	})
+	return nil
+}
+
+// Dispatch runs the synchronous handlers in order and starts the asynchronous ones.
+func (b *Bus) Dispatch(ctx context.Context, evt Event) error {
+	b.mu.RLock()
+	syncHandlers := b.sync[evt.Name()]
+	asyncHandlers := b.async[evt.Name()]
+	b.mu.RUnlock()
+
+	// Asynchronous handlers outlive the request that published the event.
+	asyncCtx := context.WithoutCancel(ctx)
+	for _, s := range asyncHandlers {
+		b.wg.Add(1)
+		go func(s subscription) {
+			defer b.wg.Done()
+			if err := b.run(asyncCtx, s.handler, evt); err != nil {
+				b.logger.Warnw("async event handler failed", "event", evt.Name(), "handler", s.name, "error", err)
+			}
+		}(s)
+	}
+
+	var errs []error
+	for _, s := range syncHandlers {
+		if err := b.run(ctx, s.handler, evt); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DispatchTracked runs the handlers of an event of the outbox but those named in handled, and
+// waits for the asynchronous ones: their failures are returned too, for the relay to retry the
+// event. markHandled records each handler that succeeded, so that a retry skips it and only
+// runs the handlers that failed.
+func (b *Bus) DispatchTracked(ctx context.Context, evt Event, handled map[string]bool, markHandled func(handler string) error) error {
+	b.mu.RLock()
+	syncHandlers := b.sync[evt.Name()]
+	asyncHandlers := b.async[evt.Name()]
+	b.mu.RUnlock()
+
+	var mu sync.Mutex
+	var errs []error
+	run := func(ctx context.Context, s subscription) {
+		err := b.run(ctx, s.handler, evt)
+		if err == nil {
+			err = markHandled(s.name)
+		}
+		if err != nil {
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			mu.Unlock()
+		}
+	}
+
+	var wg sync.WaitGroup
+	asyncCtx := context.WithoutCancel(ctx)
+	for _, s := range asyncHandlers {
+		if handled[s.name] {
+			continue
+		}
+		wg.Add(1)
+		go func(s subscription) {
+			defer wg.Done()
+			run(asyncCtx, s)
+		}(s)
+	}
+
+	for _, s := range syncHandlers {
+		if !handled[s.name] {
+			run(ctx, s)
+		}
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Wait blocks until the running asynchronous handlers are done.
+func (b *Bus) Wait() {
+	b.wg.Wait()
+}
+
+// run keeps a panicking handler from taking the other subscribers (or the server) down.
+func (b *Bus) run(ctx context.Context, h Handler, evt Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return h(ctx, evt)
+}
@@ -0,0 +1,199 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/mafi020/social/internal/db"
+	"go.uber.org/zap"
+)
+
+// recorder is a database/sql driver recording the statements it runs, and whether they ran in
+// a transaction.
+type recorder struct {
+	mu              sync.Mutex
+	begins, commits int
+	statements      []statement
+}
+
+type statement struct {
+	query string
+	inTx  bool
+}
+
+func (r *recorder) Open(string) (driver.Conn, error) {
+	return &recorderConn{r: r}, nil
+}
+
+func (r *recorder) record(query string, inTx bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, statement{query, inTx})
+}
+
+type recorderConn struct {
+	r    *recorder
+	inTx bool
+}
+
+func (c *recorderConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *recorderConn) Close() error { return nil }
+
+func (c *recorderConn) Begin() (driver.Tx, error) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.r.begins++
+	c.inTx = true
+	return c, nil
+}
+
+func (c *recorderConn) Commit() error {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.r.commits++
+	c.inTx = false
+	return nil
+}
+
+func (c *recorderConn) Rollback() error {
+	c.inTx = false
+	return nil
+}
+
+func (c *recorderConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.r.record(query, c.inTx)
+	return driver.RowsAffected(1), nil
+}
+
+var (
+	registerOnce sync.Once
+	testDriver   = &recorder{}
+)
+
+// newTestDB returns a database recording its statements in a fresh recorder.
+func newTestDB(t *testing.T) (*sql.DB, *recorder) {
+	t.Helper()
+	registerOnce.Do(func() { sql.Register("events-recorder", testDriver) })
+
+	testDriver.mu.Lock()
+	testDriver.begins, testDriver.commits, testDriver.statements = 0, 0, nil
+	testDriver.mu.Unlock()
+
+	sqlDB, err := sql.Open("events-recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB, testDriver
+}
+
+type testEvent struct{}
+
+func (testEvent) Name() string { return "test.event" }
+
+func TestPublishInTxDispatchesOutsideTheTx(t *testing.T) {
+	sqlDB, rec := newTestDB(t)
+	bus := NewBus(zap.NewNop().Sugar())
+
+	var handlerErr error
+	var handlerInTx, called bool
+	bus.Subscribe(testEvent{}.Name(), "test", func(ctx context.Context, evt Event) error {
+		called = true
+		handlerInTx = db.InTx(ctx)
+		_, handlerErr = db.Conn(ctx, sqlDB).ExecContext(ctx, "INSERT handler")
+		return handlerErr
+	})
+
+	err := db.WithTx(context.Background(), sqlDB, func(ctx context.Context) error {
+		if _, err := db.Conn(ctx, sqlDB).ExecContext(ctx, "INSERT publisher"); err != nil {
+			return err
+		}
+		if err := bus.Publish(ctx, testEvent{}); err != nil {
+			return err
+		}
+		if called {
+			t.Error("the handler ran before the transaction committed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !called {
+		t.Fatal("the handler did not run after the commit")
+	}
+	if handlerErr != nil {
+		t.Fatalf("the handler could not write: %v", handlerErr)
+	}
+	if handlerInTx {
+		t.Error("the handler got the committed transaction in its context")
+	}
+
+	want := []statement{{"INSERT publisher", true}, {"INSERT handler", false}}
+	if len(rec.statements) != len(want) || rec.statements[0] != want[0] || rec.statements[1] != want[1] {
+		t.Fatalf("got statements %v, want %v", rec.statements, want)
+	}
+}
+
+func TestHandlerTransactionsAreNotJoined(t *testing.T) {
+	sqlDB, rec := newTestDB(t)
+	bus := NewBus(zap.NewNop().Sugar())
+
+	var handlerErr error
+	bus.Subscribe(testEvent{}.Name(), "test", func(ctx context.Context, evt Event) error {
+		handlerErr = db.WithTx(ctx, sqlDB, func(ctx context.Context) error {
+			_, err := db.Conn(ctx, sqlDB).ExecContext(ctx, "INSERT handler")
+			return err
+		})
+		return handlerErr
+	})
+
+	err := db.WithTx(context.Background(), sqlDB, func(ctx context.Context) error {
+		return bus.Publish(ctx, testEvent{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handlerErr != nil {
+		t.Fatalf("the handler transaction failed: %v", handlerErr)
+	}
+	if rec.begins != 2 || rec.commits != 2 {
+		t.Fatalf("got %d transactions begun and %d committed, want 2 and 2", rec.begins, rec.commits)
+	}
+	if len(rec.statements) != 1 || !rec.statements[0].inTx {
+		t.Fatalf("got statements %v, want the handler insert in its own transaction", rec.statements)
+	}
+}
+
+func TestPublishDropsEventsOnRollback(t *testing.T) {
+	sqlDB, _ := newTestDB(t)
+	bus := NewBus(zap.NewNop().Sugar())
+
+	called := false
+	bus.Subscribe(testEvent{}.Name(), "test", func(ctx context.Context, evt Event) error {
+		called = true
+		return nil
+	})
+
+	failure := errors.New("failure")
+	err := db.WithTx(context.Background(), sqlDB, func(ctx context.Context) error {
+		if err := bus.Publish(ctx, testEvent{}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got %v, want the error of the transaction", err)
+	}
+	if called {
+		t.Fatal("the handler ran for a rolled back transaction")
+	}
+}
@@ -0,0 +1,156 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+// Event names, they double as outbox and webhook event types.
+const (
+	NamePostCreated        = "post.created"
+	NamePostUpdated        = "post.updated"
+	NamePostDeleted        = "post.deleted"
+	NamePostRestored       = "post.restored"
+	NameCommentCreated     = "comment.created"
+	NameCommentUpdated     = "comment.updated"
+	NameCommentDeleted     = "comment.deleted"
+	NameCommentRestored    = "comment.restored"
+	NameUserFollowed       = "user.followed"
+	NameUserUnfollowed     = "user.unfollowed"
+	NameUsersMentioned     = "users.mentioned"
+	NameInvitationCreated  = "invitation.created"
+	NameInvitationAccepted = "invitation.accepted"
+)
+
+type Event interface {
+	Name() string
+}
+
+type PostCreated struct {
+	Post dto.Post `json:"post"`
+}
+
+func (PostCreated) Name() string { return NamePostCreated }
+
+type PostUpdated struct {
+	Post dto.Post `json:"post"`
+}
+
+func (PostUpdated) Name() string { return NamePostUpdated }
+
+// PostDeleted is published once the post is in the trash, its comments are hidden along with it,
+// or once a repost is taken back.
+type PostDeleted struct {
+	PostID int64 `json:"post_id"`
+	UserID int64 `json:"user_id"` // author
+}
+
+func (PostDeleted) Name() string { return NamePostDeleted }
+
+// PostRestored is published once the post is out of the trash.
+type PostRestored struct {
+	Post dto.Post `json:"post"`
+}
+
+func (PostRestored) Name() string { return NamePostRestored }
+
+type CommentCreated struct {
+	Comment        dto.Comment `json:"comment"`
+	PostAuthorID   int64       `json:"post_author_id"`
+	PostVisibility string      `json:"post_visibility"`
+}
+
+func (CommentCreated) Name() string { return NameCommentCreated }
+
+type CommentUpdated struct {
+	Comment dto.Comment `json:"comment"`
+}
+
+func (CommentUpdated) Name() string { return NameCommentUpdated }
+
+type CommentDeleted struct {
+	CommentID int64 `json:"comment_id"`
+	PostID    int64 `json:"post_id"`
+}
+
+func (CommentDeleted) Name() string { return NameCommentDeleted }
+
+type CommentRestored struct {
+	Comment dto.Comment `json:"comment"`
+}
+
+func (CommentRestored) Name() string { return NameCommentRestored }
+
+type UserFollowed struct {
+	UserID     int64 `json:"user_id"`
+	FollowerID int64 `json:"follower_id"`
+}
+
+func (UserFollowed) Name() string { return NameUserFollowed }
+
+type UserUnfollowed struct {
+	UserID     int64 `json:"user_id"`
+	FollowerID int64 `json:"follower_id"`
+}
+
+func (UserUnfollowed) Name() string { return NameUserUnfollowed }
+
+// UsersMentioned is published when the edit of a published post, or a comment, mentions users
+// it did not mention before. The mentions of a post are notified when it is published, see
+// PostCreated.
+type UsersMentioned struct {
+	ActorID   int64   `json:"actor_id"`
+	PostID    int64   `json:"post_id"`
+	CommentID *int64  `json:"comment_id,omitempty"`
+	UserIDs   []int64 `json:"user_ids"`
+}
+
+func (UsersMentioned) Name() string { return NameUsersMentioned }
+
+// InvitationCreated is published for new invitations and for expired ones sent again.
+type InvitationCreated struct {
+	Invitation dto.Invitation `json:"invitation"`
+}
+
+func (InvitationCreated) Name() string { return NameInvitationCreated }
+
+type InvitationAccepted struct {
+	Invitation dto.Invitation `json:"invitation"`
+}
+
+func (InvitationAccepted) Name() string { return NameInvitationAccepted }
+
+// registry builds an empty event from its name, to decode events stored in the outbox.
+var registry = map[string]func() Event{
+	NamePostCreated:        func() Event { return &PostCreated{} },
+	NamePostUpdated:        func() Event { return &PostUpdated{} },
+	NamePostDeleted:        func() Event { return &PostDeleted{} },
+	NamePostRestored:       func() Event { return &PostRestored{} },
+	NameCommentCreated:     func() Event { return &CommentCreated{} },
+	NameCommentUpdated:     func() Event { return &CommentUpdated{} },
+	NameCommentDeleted:     func() Event { return &CommentDeleted{} },
+	NameCommentRestored:    func() Event { return &CommentRestored{} },
+	NameUserFollowed:       func() Event { return &UserFollowed{} },
+	NameUserUnfollowed:     func() Event { return &UserUnfollowed{} },
+	NameUsersMentioned:     func() Event { return &UsersMentioned{} },
+	NameInvitationCreated:  func() Event { return &InvitationCreated{} },
+	NameInvitationAccepted: func() Event { return &InvitationAccepted{} },
+}
+
+// Decode rebuilds an event from its name and JSON payload.
+func Decode(name string, payload []byte) (Event, error) {
+	newEvent, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %q", name)
+	}
+
+	evt := newEvent()
+	if err := json.Unmarshal(payload, evt); err != nil {
+		return nil, err
+	}
+	// Subscribers receive events by value, as they are published.
+	return reflect.ValueOf(evt).Elem().Interface().(Event), nil
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/interfaces"
+	"go.uber.org/zap"
+)
+
+// Relay dispatches the events stored in the outbox. Delivery is at least once: an event is
+// retried until all its handlers, synchronous and asynchronous, succeed. Retries only run the
+// handlers that have not succeeded yet, see Bus.DispatchTracked.
+type Relay struct {
+	store        interfaces.OutboxInterface
+	bus          *Bus
+	logger       *zap.SugaredLogger
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+}
+
+func NewRelay(store interfaces.OutboxInterface, bus *Bus, logger *zap.SugaredLogger, pollInterval time.Duration, batchSize, maxAttempts int) *Relay {
+	return &Relay{
+		store:        store,
+		bus:          bus,
+		logger:       logger,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		maxAttempts:  maxAttempts,
+	}
+}
+
+// Run polls the outbox until the context is canceled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.processBatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) processBatch(ctx context.Context) {
+	pending, err := r.store.ClaimPending(ctx, r.batchSize, time.Minute)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Warnw("failed to claim outbox events", "error", err)
+		}
+		return
+	}
+
+	for i := range pending {
+		r.process(ctx, &pending[i])
+	}
+}
+
+func (r *Relay) process(ctx context.Context, e *dto.OutboxEvent) {
+	// The outcome is recorded even if the relay is being stopped.
+	recordCtx := context.WithoutCancel(ctx)
+
+	evt, err := Decode(e.Name, e.Payload)
+	if err == nil {
+		err = r.dispatch(ctx, e.ID, evt)
+	}
+
+	if err == nil {
+		if err := r.store.MarkProcessed(recordCtx, e.ID); err != nil {
+			r.logger.Warnw("failed to mark outbox event as processed", "id", e.ID, "error", err)
+		}
+		return
+	}
+
+	e.Attempts++
+	var retryAt *time.Time
+	if e.Attempts < r.maxAttempts {
+		next := time.Now().Add(time.Duration(e.Attempts*e.Attempts) * 10 * time.Second)
+		retryAt = &next
+	}
+
+	r.logger.Warnw("outbox event failed", "id", e.ID, "event", e.Name, "attempts", e.Attempts, "error", err)
+	if err := r.store.MarkFailed(recordCtx, e.ID, e.Attempts, err.Error(), retryAt); err != nil {
+		r.logger.Warnw("failed to record outbox failure", "id", e.ID, "error", err)
+	}
+}
+
+// dispatch runs the handlers of the event that have not handled it yet.
+func (r *Relay) dispatch(ctx context.Context, id int64, evt Event) error {
+	names, err := r.store.GetHandlers(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	handled := make(map[string]bool, len(names))
+	for _, name := range names {
+		handled[name] = true
+	}
+
+	return r.bus.DispatchTracked(ctx, evt, handled, func(handler string) error {
+		return r.store.MarkHandled(context.WithoutCancel(ctx), id, handler)
+	})
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+	"go.uber.org/zap"
+)
+
+// memoryOutbox is an outbox kept in memory. Failed events are pending again right away.
+type memoryOutbox struct {
+	mu        sync.Mutex
+	events    []dto.OutboxEvent
+	processed map[int64]bool
+	handlers  map[int64][]string
+}
+
+func newMemoryOutbox() *memoryOutbox {
+	return &memoryOutbox{processed: make(map[int64]bool), handlers: make(map[int64][]string)}
+}
+
+func (o *memoryOutbox) Insert(ctx context.Context, name string, payload []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, dto.OutboxEvent{ID: int64(len(o.events) + 1), Name: name, Payload: payload})
+	return nil
+}
+
+func (o *memoryOutbox) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]dto.OutboxEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var pending []dto.OutboxEvent
+	for _, e := range o.events {
+		if !o.processed[e.ID] && len(pending) < limit {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+func (o *memoryOutbox) MarkProcessed(ctx context.Context, id int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.processed[id] = true
+	return nil
+}
+
+func (o *memoryOutbox) MarkFailed(ctx context.Context, id int64, attempts int, lastError string, retryAt *time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events[id-1].Attempts = attempts
+	return nil
+}
+
+func (o *memoryOutbox) GetHandlers(ctx context.Context, id int64) ([]string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.handlers[id]...), nil
+}
+
+func (o *memoryOutbox) MarkHandled(ctx context.Context, id int64, handler string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.handlers[id] = append(o.handlers[id], handler)
+	return nil
+}
+
+func TestRelayRetriesOnlyTheFailedHandlers(t *testing.T) {
+	for _, failing := range []string{"sync", "async"} {
+		t.Run(failing, func(t *testing.T) {
+			outbox := newMemoryOutbox()
+			bus := NewBus(zap.NewNop().Sugar())
+			bus.UseOutbox(outbox)
+
+			var mu sync.Mutex
+			calls := make(map[string]int)
+			handler := func(name string) Handler {
+				return func(ctx context.Context, evt Event) error {
+					mu.Lock()
+					defer mu.Unlock()
+					calls[name]++
+					if name == failing && calls[name] == 1 {
+						return errors.New("failure")
+					}
+					return nil
+				}
+			}
+			bus.Subscribe(NamePostCreated, "sync", handler("sync"))
+			bus.Subscribe(NamePostCreated, "other", handler("other"))
+			bus.SubscribeAsync(NamePostCreated, "async", handler("async"))
+
+			if err := bus.Publish(context.Background(), PostCreated{Post: dto.Post{ID: 1}}); err != nil {
+				t.Fatal(err)
+			}
+
+			relay := NewRelay(outbox, bus, zap.NewNop().Sugar(), time.Second, 10, 5)
+			relay.processBatch(context.Background())
+			if outbox.processed[1] {
+				t.Fatal("the event was processed although a handler failed")
+			}
+
+			relay.processBatch(context.Background())
+			if !outbox.processed[1] {
+				t.Fatal("the event was not processed once its handlers succeeded")
+			}
+
+			want := map[string]int{"sync": 1, "other": 1, "async": 1}
+			want[failing] = 2
+			for name, n := range want {
+				if calls[name] != n {
+					t.Errorf("handler %s ran %d times, want %d", name, calls[name], n)
+				}
+			}
+		})
+	}
+}
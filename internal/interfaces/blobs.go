@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+)
+
+// BlobStore stores the uploaded files, on the local disk or in an S3-compatible bucket. Keys
+// are slash-separated paths.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL is the URL the blob is served from, for the clients allowed to see it.
+	URL(key string) string
+}
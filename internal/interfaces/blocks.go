@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+)
+
+type BlocksInterface interface {
+	Block(ctx context.Context, userID, blockedID int64) error
+	Unblock(ctx context.Context, userID, blockedID int64) error
+	Mute(ctx context.Context, userID, mutedID int64) error
+	Unmute(ctx context.Context, userID, mutedID int64) error
+	GetBlockedUserIDs(ctx context.Context, viewerID int64) ([]int64, error)
+}
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/mafi020/social/internal/dto"
 )
@@ -10,6 +11,14 @@ type CommentsInterface interface {
 	Create(context.Context, *dto.Comment) error
 	GetCommentsByPostID(context.Context, int64) ([]dto.Comment, error)
 	GetByID(context.Context, int64) (*dto.Comment, error)
+	GetByIDs(ctx context.Context, ids []int64) ([]dto.CommentWithPostAuthor, error)
+	ListAfterID(ctx context.Context, afterID int64, limit int) ([]dto.CommentWithPostAuthor, error)
+	ListContentAfterID(ctx context.Context, afterID int64, limit int) ([]dto.Comment, error)
+	SetContentHTML(ctx context.Context, commentID int64, html string) error
+	GetLatestByPostIDs(ctx context.Context, viewerID int64, postIDs []int64, perPost int) ([]dto.Comment, error)
 	Update(context.Context, *dto.Comment) error
 	Delete(context.Context, int64) error
+	GetDeletedByUserID(ctx context.Context, userID int64) ([]dto.Comment, error)
+	Restore(ctx context.Context, commentID, userID int64) (*dto.Comment, error)
+	Purge(ctx context.Context, deletedBefore time.Time, limit int) (int64, error)
 }
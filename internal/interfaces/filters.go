@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type ContentFiltersInterface interface {
+	Create(context.Context, *dto.ContentFilter) error
+	GetActiveByUserID(ctx context.Context, userID int64) ([]dto.ContentFilter, error)
+	Delete(ctx context.Context, userID, filterID int64) error
+}
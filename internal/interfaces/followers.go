@@ -7,4 +7,5 @@ import (
 type FollowersInterface interface {
 	Follow(ctx context.Context, userID, followerID int64) error
 	UnFollow(ctx context.Context, userIDToUnfollow, followerID int64) error
+	GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error)
 }
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type MediaInterface interface {
+	Create(context.Context, *dto.Media) error
+	Attach(ctx context.Context, postID, userID int64, ids []int64) error
+	GetByPostIDs(ctx context.Context, postIDs []int64) ([]dto.Media, error)
+	GetUnattached(ctx context.Context, createdBefore time.Time, limit int) ([]dto.Media, error)
+	Delete(ctx context.Context, ids []int64) ([]int64, error)
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type MentionsInterface interface {
+	Set(ctx context.Context, postID int64, commentID *int64, userIDs []int64) ([]int64, error)
+	GetByPostIDs(ctx context.Context, postIDs []int64) ([]dto.Mention, error)
+	GetByCommentIDs(ctx context.Context, commentIDs []int64) ([]dto.Mention, error)
+	GetNotifiable(ctx context.Context, actorID, postID int64, userIDs []int64) ([]int64, error)
+}
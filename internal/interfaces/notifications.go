@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type NotificationsInterface interface {
+	Create(context.Context, *dto.Notification) error
+	GetByUserID(context.Context, int64, dto.NotificationQueryParams) ([]dto.Notification, error)
+	MarkRead(ctx context.Context, userID, notificationID int64) error
+	MarkAllRead(ctx context.Context, userID int64) (int64, error)
+	UnreadCount(ctx context.Context, userID int64) (int, error)
+}
@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type OutboxInterface interface {
+	Insert(ctx context.Context, name string, payload []byte) error
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]dto.OutboxEvent, error)
+	MarkProcessed(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, attempts int, lastError string, retryAt *time.Time) error
+	GetHandlers(ctx context.Context, id int64) ([]string, error)
+	MarkHandled(ctx context.Context, id int64, handler string) error
+}
@@ -2,14 +2,33 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/mafi020/social/internal/dto"
 )
 
 type PostsInterface interface {
 	Create(context.Context, *dto.Post) error
-	GetByID(context.Context, int64) (*dto.Post, error)
-	Delete(context.Context, int64) error
+	GetByID(ctx context.Context, postID, viewerID int64) (*dto.Post, error)
+	GetByIDs(ctx context.Context, ids []int64) ([]dto.Post, error)
+	EmbedOriginals(ctx context.Context, viewerID int64, posts ...*dto.Post) error
+	Repost(ctx context.Context, userID, postID int64) (*dto.Post, bool, error)
+	DeleteRepost(ctx context.Context, userID, postID int64) (int64, error)
+	ListAfterID(ctx context.Context, afterID int64, limit int) ([]dto.Post, error)
+	ListContentAfterID(ctx context.Context, afterID int64, limit int) ([]dto.Post, error)
+	SetContentHTML(ctx context.Context, postID int64, html string) error
+	GetLatestByUserID(ctx context.Context, userID int64, limit int) ([]dto.Post, error)
+	GetLatestByTag(ctx context.Context, tag string, limit int) ([]dto.Post, error)
+	GetUnpublishedByUserID(ctx context.Context, userID int64) ([]dto.Post, error)
+	PublishDue(ctx context.Context, limit int) ([]dto.Post, error)
+	Delete(context.Context, int64) (int64, error)
+	GetDeletedByUserID(ctx context.Context, userID int64) ([]dto.Post, error)
+	Restore(ctx context.Context, postID, userID int64) (*dto.Post, error)
+	Purge(ctx context.Context, deletedBefore time.Time, limit int) (int64, error)
 	Update(context.Context, *dto.Post) error
 	Feed(context.Context, int64, dto.FeedQueryParams) ([]dto.Feed, int, error)
+	FeedByCursor(context.Context, int64, dto.FeedQueryParams) ([]dto.Feed, bool, error)
+	CountFeed(ctx context.Context, userID int64, params dto.FeedQueryParams, max int) (int, error)
+	Explore(ctx context.Context, viewerID int64, params dto.FeedQueryParams) ([]dto.Feed, bool, error)
+	TopFeed(ctx context.Context, userID int64, params dto.FeedQueryParams, ranking dto.FeedRanking) ([]dto.Feed, int, error)
 }
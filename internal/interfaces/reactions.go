@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type ReactionsInterface interface {
+	Set(ctx context.Context, target string, targetID, userID int64, reactionType string) error
+	Delete(ctx context.Context, target string, targetID, userID int64) error
+	GetCounts(ctx context.Context, target string, targetIDs []int64, viewerID int64) ([]dto.ReactionCount, error)
+	GetByTarget(ctx context.Context, target string, targetID, viewerID int64, params dto.ReactionQueryParams) ([]dto.Reaction, error)
+}
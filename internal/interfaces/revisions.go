@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type PostRevisionsInterface interface {
+	Create(ctx context.Context, postID, editorID int64) error
+	GetByPostID(ctx context.Context, postID int64) ([]dto.PostRevision, error)
+	GetByRevision(ctx context.Context, postID int64, revision int) (*dto.PostRevision, error)
+}
@@ -0,0 +1,21 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type SearchInterface interface {
+	Search(ctx context.Context, viewerID int64, params dto.SearchQueryParams) ([]dto.SearchHit, error)
+	Reindex(ctx context.Context) error
+}
+
+// SearchIndex is the search backend. Documents are posts and comments, users are only searched
+// in Postgres.
+type SearchIndex interface {
+	Index(ctx context.Context, doc dto.SearchDocument) error
+	// Delete removes a document. Deleting a post also removes its comments.
+	Delete(ctx context.Context, docType string, id int64) error
+	Query(ctx context.Context, viewerID int64, params dto.SearchQueryParams) ([]dto.SearchHit, error)
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type TagsInterface interface {
+	Autocomplete(ctx context.Context, prefix string, limit int) ([]dto.Tag, error)
+	Trending(ctx context.Context, window, baseline time.Duration, minPosts, limit int) ([]dto.TrendingTag, error)
+	DenyTag(ctx context.Context, tag *dto.DeniedTag) error
+	AllowTag(ctx context.Context, tag string) error
+	GetDeniedTags(ctx context.Context) ([]dto.DeniedTag, error)
+}
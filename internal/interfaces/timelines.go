@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type TimelinesInterface interface {
+	FanOut(ctx context.Context, post *dto.Post, maxFollowers int) (bool, error)
+	Backfill(ctx context.Context, userID, authorID int64, limit int) error
+	RemoveAuthor(ctx context.Context, userID, authorID int64) error
+}
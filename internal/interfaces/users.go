@@ -10,7 +10,9 @@ type UsersInterface interface {
 	Create(context.Context, *dto.User) error
 	GetByEmail(context.Context, string) (*dto.User, error)
 	GetByUsername(context.Context, string) (*dto.User, error)
+	GetByUsernames(ctx context.Context, usernames []string) ([]dto.User, error)
 	IsUserUnique(context.Context, string, string) (map[string]string, error)
 	GetById(context.Context, int64) (*dto.User, error)
+	GetProfilesByIDs(ctx context.Context, viewerID int64, ids []int64) ([]dto.UserProfile, error)
 	Delete(context.Context, int64) error
 }
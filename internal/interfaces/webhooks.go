@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+type WebhooksInterface interface {
+	CreateSubscription(context.Context, *dto.WebhookSubscription) error
+	GetSubscriptionByID(context.Context, int64) (*dto.WebhookSubscription, error)
+	GetSubscriptionsByUserID(context.Context, int64) ([]dto.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, userID, subscriptionID int64) error
+	Enqueue(ctx context.Context, eventType string, payload []byte) (int64, error)
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]dto.WebhookDelivery, error)
+	RecordAttempt(context.Context, *dto.WebhookDelivery) error
+	GetDeliveriesBySubscriptionID(context.Context, int64, dto.WebhookDeliveryQueryParams) ([]dto.WebhookDelivery, error)
+}
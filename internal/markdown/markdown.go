@@ -0,0 +1,183 @@
+// Package markdown renders the CommonMark content of posts and comments to sanitized HTML.
+//
+// Raw HTML is never rendered. The output goes through a strict allow-list on top of that: text
+// formatting, lists, quotes, code and links, the links carrying rel="nofollow ugc". Mentions
+// (@username) and hashtags (#tag) are linked to the relative URLs /@username and /tags/tag,
+// with the classes "mention" and "hashtag", for the clients to route.
+package markdown
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mafi020/social/internal/utils"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+const linkRel = "nofollow ugc"
+
+var md = goldmark.New(
+	goldmark.WithParserOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(&autoLinkParser{trigger: '@', class: "mention", url: mentionURL}, 500),
+			util.Prioritized(&autoLinkParser{trigger: '#', class: "hashtag", url: hashtagURL}, 500),
+		),
+		parser.WithASTTransformers(
+			util.Prioritized(linkRelTransformer{}, 500),
+		),
+	),
+)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements(
+		"p", "br", "hr", "em", "strong", "code", "pre", "blockquote",
+		"ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6",
+	)
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs(true)
+	p.RequireParseableURLs(true)
+	p.AllowAttrs("rel").Matching(regexp.MustCompile(`^` + linkRel + `$`)).OnElements("a")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(mention|hashtag)$`)).OnElements("a")
+
+	return p
+}
+
+// Render returns the sanitized HTML of the CommonMark source.
+func Render(source string) string {
+	var buf bytes.Buffer
+	// Only writes fail, and writes to a buffer don't
+	_ = md.Convert([]byte(source), &buf)
+	return string(policy.SanitizeBytes(buf.Bytes()))
+}
+
+// Hashtags returns the hashtags of the source, without their #, once each, in order of
+// appearance. They are the ones Render links to, see Mentions.
+func Hashtags(source string) []string {
+	return entities(source, "hashtag")
+}
+
+// Mentions returns the usernames mentioned in the source, once each, in order of appearance.
+// They are the ones Render links to: @username in code or in the text of a link is no mention.
+func Mentions(source string) []string {
+	return entities(source, "mention")
+}
+
+// entities returns the names of the links of the class, without their trigger character.
+func entities(source, class string) []string {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	names := []string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Kind() != ast.KindLink {
+			return ast.WalkContinue, nil
+		}
+		if c, ok := n.AttributeString("class"); ok && string(c.([]byte)) == class {
+			if t, ok := n.FirstChild().(*ast.Text); ok {
+				name := string(t.Segment.Value(src)[1:])
+				if !slices.Contains(names, name) {
+					names = append(names, name)
+				}
+			}
+		}
+		return ast.WalkSkipChildren, nil
+	})
+
+	return names
+}
+
+func mentionURL(username string) string {
+	return "/@" + url.PathEscape(username)
+}
+
+// hashtagURL links to the normalized tag, the one the post is tagged with.
+func hashtagURL(tag string) string {
+	if normalized, ok := utils.NormalizeTag(tag); ok {
+		tag = normalized
+	}
+	return "/tags/" + url.PathEscape(tag)
+}
+
+// autoLinkParser links the words following the trigger character, e.g. @username or #tag. The
+// trigger must not follow a word character, so that e-mail addresses and URL fragments are
+// left alone.
+type autoLinkParser struct {
+	trigger byte
+	class   string
+	url     func(name string) string
+}
+
+func (p *autoLinkParser) Trigger() []byte {
+	return []byte{p.trigger}
+}
+
+func (p *autoLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	// Links cannot contain other links
+	if pc.IsInLinkLabel() || isWordRune(block.PrecendingCharacter()) {
+		return nil
+	}
+
+	line, segment := block.PeekLine()
+	n := 1
+	for n < len(line) {
+		r, size := utf8.DecodeRune(line[n:])
+		if !isWordRune(r) {
+			break
+		}
+		n += size
+	}
+
+	// Names start with a letter, #1 is no hashtag
+	first, _ := utf8.DecodeRune(line[1:n])
+	if n == 1 || !unicode.IsLetter(first) {
+		return nil
+	}
+
+	name := string(line[1:n])
+	block.Advance(n)
+
+	link := ast.NewLink()
+	link.Destination = []byte(p.url(name))
+	link.AppendChild(link, ast.NewTextSegment(segment.WithStop(segment.Start+n)))
+	link.SetAttributeString("class", []byte(p.class))
+	return link
+}
+
+// isWordRune reports whether the rune is part of a word, combining marks included so that
+// decomposed accented letters don't end a name.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// linkRelTransformer marks every link as user generated content.
+type linkRelTransformer struct{}
+
+func (linkRelTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindLink, ast.KindAutoLink:
+			n.SetAttributeString("rel", []byte(linkRel))
+		}
+		return ast.WalkContinue, nil
+	})
+}
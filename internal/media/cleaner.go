@@ -0,0 +1,96 @@
+package media
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/mafi020/social/internal/interfaces"
+	"go.uber.org/zap"
+)
+
+type CleanerConfig struct {
+	MaxAge       time.Duration // how long uploads may stay unattached
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// Cleaner removes the media attached to no post: uploads never attached, attachments removed
+// from their post, and the attachments of purged posts.
+type Cleaner struct {
+	media  interfaces.MediaInterface
+	blobs  interfaces.BlobStore
+	logger *zap.SugaredLogger
+	cfg    CleanerConfig
+}
+
+func NewCleaner(media interfaces.MediaInterface, blobs interfaces.BlobStore, logger *zap.SugaredLogger, cfg CleanerConfig) *Cleaner {
+	return &Cleaner{
+		media:  media,
+		blobs:  blobs,
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// Run removes the unattached media until the context is canceled.
+func (c *Cleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := c.cleanBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					c.logger.Warnw("failed to remove unattached media", "error", err)
+				}
+				break
+			}
+			if n < c.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanBatch deletes the rows before the blobs, so that media attached meanwhile are kept. A
+// blob failing to be deleted is left behind, never an attachment without its blob.
+func (c *Cleaner) cleanBatch(ctx context.Context) (int, error) {
+	media, err := c.media.GetUnattached(ctx, time.Now().Add(-c.cfg.MaxAge), c.cfg.BatchSize)
+	if err != nil || len(media) == 0 {
+		return 0, err
+	}
+
+	ids := make([]int64, len(media))
+	for i, m := range media {
+		ids[i] = m.ID
+	}
+
+	deleted, err := c.media.Delete(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range media {
+		if !slices.Contains(deleted, m.ID) {
+			continue
+		}
+		for _, key := range []string{m.StorageKey, m.ThumbnailKey} {
+			if err := c.blobs.Delete(ctx, key); err != nil {
+				c.logger.Warnw("failed to delete blob", "key", key, "error", err)
+			}
+		}
+	}
+
+	if len(deleted) > 0 {
+		c.logger.Infow("Unattached media removed", "count", len(deleted))
+	}
+	return len(media), nil
+}
@@ -0,0 +1,77 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mafi020/social/internal/errs"
+)
+
+func TestLocalStoreRejectsKeysOutsideTheRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "media")
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewLocalStore(root, "http://localhost/api/media/files", NewURLSigner("test", time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", ".", "..", "../secret", "a/../../secret", "/etc/passwd", "a//b", "a/./b"} {
+		if _, err := s.Get(context.Background(), key); !errors.Is(err, errs.ErrNotFound) {
+			t.Errorf("Get(%q) = %v, want %v", key, err, errs.ErrNotFound)
+		}
+		if err := s.Put(context.Background(), key, "image/png", []byte("x")); !errors.Is(err, errs.ErrNotFound) {
+			t.Errorf("Put(%q) = %v, want %v", key, err, errs.ErrNotFound)
+		}
+		if err := s.Delete(context.Background(), key); !errors.Is(err, errs.ErrNotFound) {
+			t.Errorf("Delete(%q) = %v, want %v", key, err, errs.ErrNotFound)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "secret"))
+	if err != nil || string(data) != "secret" {
+		t.Fatalf("file outside the root changed: %q, %v", data, err)
+	}
+}
+
+func TestLocalStorePutGet(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir(), "http://localhost/api/media/files/", NewURLSigner("test", time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "2026/10/abc.png"
+	if err := s.Put(context.Background(), key, "image/png", []byte("png")); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blob.Close()
+	if data, _ := io.ReadAll(blob); string(data) != "png" {
+		t.Errorf("Get() = %q, want %q", data, "png")
+	}
+
+	u, err := url.Parse(s.URL(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(u.String(), "http://localhost/api/media/files/"+key+"?") {
+		t.Errorf("URL() = %q", u)
+	}
+	if err := s.signer.Verify(key, u.Query(), time.Now()); err != nil {
+		t.Errorf("URL() is not signed: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+// Package media processes uploaded files, and stores them on the local disk or in an
+// S3-compatible bucket.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // image decoders
+	"image/jpeg"
+	_ "image/png"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/mafi020/social/internal/utils"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// allowedTypes are the accepted MIME types, detected from the content of the files.
+var allowedTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+type Config struct {
+	MaxSize       int64 // bytes
+	MaxPixels     int   // width * height, decoding larger images could exhaust the memory
+	ThumbnailSize int   // longest edge of the thumbnails, in pixels
+}
+
+// File is an uploaded file once processed.
+type File struct {
+	MimeType  string
+	Extension string // with the leading dot
+	Width     int
+	Height    int
+	Thumbnail []byte // JPEG
+}
+
+// Process sniffs the type of the file, reads its dimensions and renders its thumbnail. The
+// extension or the content type declared by the client are ignored.
+func Process(data []byte, cfg Config) (*File, error) {
+	mime := mimetype.Detect(data)
+	if !mimetype.EqualsAny(mime.String(), allowedTypes...) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, mime.String())
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if config.Width*config.Height > cfg.MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, thumbnail(img, cfg.ThumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+
+	return &File{
+		MimeType:  mime.String(),
+		Extension: mime.Extension(),
+		Width:     config.Width,
+		Height:    config.Height,
+		Thumbnail: thumb.Bytes(),
+	}, nil
+}
+
+// NewKeys returns random keys for a file with the extension and for its thumbnail.
+func NewKeys(extension string) (key, thumbnailKey string, err error) {
+	token, err := utils.GenerateToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	prefix := time.Now().UTC().Format("2006/01/") + token
+	return prefix + extension, prefix + "_thumb.jpg", nil
+}
+
+// thumbnail scales the image down so that its longest edge is at most size pixels, averaging
+// the pixels each thumbnail pixel covers. Transparent areas are rendered white, for JPEG.
+func thumbnail(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	tw, th := w, h
+	switch {
+	case w >= h && w > size:
+		tw, th = size, max(1, h*size/w)
+	case h > w && h > size:
+		tw, th = max(1, w*size/h), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	if tw == w && th == h {
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+		return dst
+	}
+
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+
+			// Colors are alpha-premultiplied, compositing over white adds the missing alpha
+			white := n*0xffff - a
+			dst.Set(x, y, color.RGBA64{
+				R: uint16((r + white) / n),
+				G: uint16((g + white) / n),
+				B: uint16((bl + white) / n),
+				A: 0xffff,
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,74 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	cfg := Config{MaxPixels: 200 * 100, ThumbnailSize: 50}
+
+	file, err := Process(encodePNG(t, 200, 100), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.MimeType != "image/png" || file.Extension != ".png" || file.Width != 200 || file.Height != 100 {
+		t.Errorf("Process() = %s %s %dx%d", file.MimeType, file.Extension, file.Width, file.Height)
+	}
+
+	thumb, err := jpeg.Decode(bytes.NewReader(file.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 50 || b.Dy() != 25 {
+		t.Errorf("thumbnail is %dx%d, want 50x25", b.Dx(), b.Dy())
+	}
+}
+
+func TestProcessRejectsTooManyPixels(t *testing.T) {
+	cfg := Config{MaxPixels: 200 * 100, ThumbnailSize: 50}
+
+	for _, size := range [][2]int{{201, 100}, {200, 101}, {20001, 1}} {
+		if _, err := Process(encodePNG(t, size[0], size[1]), cfg); !errors.Is(err, ErrTooManyPixels) {
+			t.Errorf("Process(%dx%d) = %v, want %v", size[0], size[1], err, ErrTooManyPixels)
+		}
+	}
+}
+
+// TestProcessChecksDimensionsBeforeDecoding feeds a PNG header declaring a huge image: decoding
+// it would allocate gigabytes.
+func TestProcessChecksDimensionsBeforeDecoding(t *testing.T) {
+	data := encodePNG(t, 1, 1)
+	// Width and height are the first fields of the 13 bytes IHDR chunk, after the 8 bytes
+	// signature and the 8 bytes of the chunk length and type, and before the chunk CRC.
+	copy(data[16:24], []byte{0, 0, 0x80, 0, 0, 0, 0x80, 0}) // 32768x32768
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+	if _, err := Process(data, Config{MaxPixels: 4096 * 4096, ThumbnailSize: 400}); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Process() = %v, want %v", err, ErrTooManyPixels)
+	}
+}
+
+func TestProcessRejectsUnsupportedTypes(t *testing.T) {
+	for _, data := range [][]byte{[]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"/>"), []byte("plain text")} {
+		if _, err := Process(data, Config{MaxPixels: 100, ThumbnailSize: 50}); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("Process(%q) = %v, want %v", data, err, ErrUnsupportedType)
+		}
+	}
+}
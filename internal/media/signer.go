@@ -0,0 +1,64 @@
+package media
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("invalid or expired media URL")
+
+// URLSigner signs the URLs of the blobs served by the API. The URLs are only handed out along
+// with the posts the viewer may read, and expire: a blob is not reachable by whoever kept the
+// URL once its post is deleted or hidden from them.
+type URLSigner struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewURLSigner returns a signer of URLs valid for ttl to 2*ttl. Expiries are rounded, so that
+// the URLs of a blob stay the same, and cacheable, for ttl.
+func NewURLSigner(secret string, ttl time.Duration) *URLSigner {
+	// Derived, the secret may be shared with other signatures
+	return &URLSigner{key: hmacSHA256([]byte(secret), "media-urls"), ttl: ttl}
+}
+
+// Sign returns the query parameters of the URL of the blob with the key.
+func (s *URLSigner) Sign(key string, now time.Time) url.Values {
+	expires := strconv.FormatInt(now.Truncate(s.ttl).Add(2*s.ttl).Unix(), 10)
+	return url.Values{
+		"expires":   {expires},
+		"signature": {s.signature(key, expires)},
+	}
+}
+
+// Verify checks the query parameters of a URL of the blob with the key, and returns
+// ErrInvalidSignature when they were not signed for it or have expired.
+func (s *URLSigner) Verify(key string, query url.Values, now time.Time) error {
+	expires := query.Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() >= unix {
+		return ErrInvalidSignature
+	}
+
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	want, _ := hex.DecodeString(s.signature(key, expires))
+	if !hmac.Equal(signature, want) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func (s *URLSigner) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,45 @@
+package media
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner("secret", time.Hour)
+	now := time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+	query := signer.Sign("2026/10/abc.png", now)
+
+	if err := signer.Verify("2026/10/abc.png", query, now); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+
+	// URLs are stable within the hour, for caches
+	if later := signer.Sign("2026/10/abc.png", now.Add(20*time.Minute)); later.Encode() != query.Encode() {
+		t.Errorf("Sign() changed within the hour: %q, %q", query.Encode(), later.Encode())
+	}
+
+	tampered := url.Values{"expires": {"9999999999"}, "signature": query["signature"]}
+	tests := []struct {
+		name  string
+		key   string
+		query url.Values
+		now   time.Time
+	}{
+		{"other key", "2026/10/abd.png", query, now},
+		{"expired", "2026/10/abc.png", query, now.Add(2 * time.Hour)},
+		{"extended expiry", "2026/10/abc.png", tampered, now},
+		{"other secret", "2026/10/abc.png", NewURLSigner("other", time.Hour).Sign("2026/10/abc.png", now), now},
+		{"unsigned", "2026/10/abc.png", url.Values{}, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := signer.Verify(tt.key, tt.query, tt.now); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify() = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
@@ -28,20 +28,43 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Validate token
-		claims, err := utils.ValidateToken(parts[1])
-		if err != nil {
+		serveWithToken(next, w, r, parts[1])
+	})
+}
+
+// StreamAuthMiddleware authenticates long-lived connections. Browsers can't set headers on
+// EventSource and WebSocket requests, so the access token may also come as ?access_token=.
+func StreamAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			AuthMiddleware(next).ServeHTTP(w, r)
+			return
+		}
 
-			utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]string{"message": "Invalid or expired token"})
+		token := r.URL.Query().Get("access_token")
+		if token == "" {
+			utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]string{"message": "Missing access token"})
 			return
 		}
 
-		// Put user ID into context
-		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		serveWithToken(next, w, r, token)
 	})
 }
 
+func serveWithToken(next http.Handler, w http.ResponseWriter, r *http.Request, token string) {
+	// Validate token
+	claims, err := utils.ValidateToken(token)
+	if err != nil {
+
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]string{"message": "Invalid or expired token"})
+		return
+	}
+
+	// Put user ID into context
+	ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // Helper to get userID from context in handlers
 func GetAuthUserIDFromContext(r *http.Request) int64 {
 	if userID, ok := r.Context().Value(UserIDKey).(int64); ok {
@@ -0,0 +1,93 @@
+// Package scheduler publishes scheduled posts when their publication time comes.
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/mafi020/social/internal/events"
+	"github.com/mafi020/social/internal/interfaces"
+	"go.uber.org/zap"
+)
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// TxFunc runs fn in a transaction, see store.Storage.WithTx.
+type TxFunc func(ctx context.Context, fn func(ctx context.Context) error) error
+
+// Publisher publishes the due scheduled posts. Each post is published in a transaction along
+// with its events.PostCreated, which fires the usual side effects: fan-out, search indexing,
+// webhooks and streaming.
+type Publisher struct {
+	posts  interfaces.PostsInterface
+	withTx TxFunc
+	bus    *events.Bus
+	logger *zap.SugaredLogger
+	cfg    Config
+}
+
+func NewPublisher(posts interfaces.PostsInterface, withTx TxFunc, bus *events.Bus, logger *zap.SugaredLogger, cfg Config) *Publisher {
+	return &Publisher{
+		posts:  posts,
+		withTx: withTx,
+		bus:    bus,
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// Run polls for due posts until the context is canceled.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch may leave due posts behind, publish them without waiting
+		for {
+			n, err := p.publishBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					p.logger.Warnw("failed to publish scheduled posts", "error", err)
+				}
+				break
+			}
+			if n < p.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Publisher) publishBatch(ctx context.Context) (int, error) {
+	var published int
+	err := p.withTx(ctx, func(ctx context.Context) error {
+		posts, err := p.posts.PublishDue(ctx, p.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, post := range posts {
+			if err := p.bus.Publish(ctx, events.PostCreated{Post: post}); err != nil {
+				return err
+			}
+		}
+		published = len(posts)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if published > 0 {
+		p.logger.Infow("Scheduled posts published", "count", published)
+	}
+	return published, nil
+}
@@ -0,0 +1,336 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+	"github.com/mafi020/social/internal/interfaces"
+)
+
+// BleveIndex searches posts and comments in an embedded on-disk Bleve index, fed by the domain
+// events. Hits are loaded from the store, so the index only holds what is needed to match and
+// filter them. Users are searched with the fallback index.
+type BleveIndex struct {
+	index    bleve.Index
+	posts    interfaces.PostsInterface
+	comments interfaces.CommentsInterface
+	blocks   interfaces.BlocksInterface
+	filters  interfaces.ContentFiltersInterface
+	fallback interfaces.SearchIndex
+}
+
+// noLanguage is indexed as the language of posts without one, which every user sees.
+const noLanguage = "und"
+
+// OpenBleve opens the index at path, creating it if it does not exist.
+func OpenBleve(path string) (bleve.Index, error) {
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		return bleve.New(path, newBleveMapping())
+	}
+	return index, err
+}
+
+func NewBleveIndex(index bleve.Index, posts interfaces.PostsInterface, comments interfaces.CommentsInterface, blocks interfaces.BlocksInterface, filters interfaces.ContentFiltersInterface, fallback interfaces.SearchIndex) *BleveIndex {
+	return &BleveIndex{
+		index:    index,
+		posts:    posts,
+		comments: comments,
+		blocks:   blocks,
+		filters:  filters,
+		fallback: fallback,
+	}
+}
+
+func newBleveMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = en.AnalyzerName
+	text.Store = true // needed to highlight the matches
+	text.IncludeTermVectors = true
+
+	keyword := bleve.NewKeywordFieldMapping()
+	keyword.IncludeInAll = false
+
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt("type", keyword)
+	doc.AddFieldMappingsAt("user_id", keyword)
+	doc.AddFieldMappingsAt("post_id", keyword)
+	doc.AddFieldMappingsAt("post_author_id", keyword)
+	doc.AddFieldMappingsAt("title", text)
+	doc.AddFieldMappingsAt("content", text)
+	doc.AddFieldMappingsAt("tags", bleve.NewKeywordFieldMapping())
+	doc.AddFieldMappingsAt("language", keyword)
+	doc.AddFieldMappingsAt("visibility", keyword)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.DefaultAnalyzer = en.AnalyzerName
+	return m
+}
+
+func docID(docType string, id int64) string {
+	return docType + ":" + strconv.FormatInt(id, 10)
+}
+
+func bleveFields(doc dto.SearchDocument) map[string]any {
+	fields := map[string]any{
+		"type":    doc.Type,
+		"user_id": strconv.FormatInt(doc.UserID, 10),
+		"content": doc.Content,
+	}
+	if doc.Type == dto.SearchTypePosts {
+		fields["title"] = doc.Title
+		fields["tags"] = doc.Tags
+		fields["visibility"] = doc.Visibility
+		fields["language"] = doc.Language
+		if doc.Language == "" {
+			fields["language"] = noLanguage
+		}
+	} else {
+		fields["post_id"] = strconv.FormatInt(doc.PostID, 10)
+		fields["post_author_id"] = strconv.FormatInt(doc.PostAuthorID, 10)
+	}
+	return fields
+}
+
+func (b *BleveIndex) Index(ctx context.Context, doc dto.SearchDocument) error {
+	return b.index.Index(docID(doc.Type, doc.ID), bleveFields(doc))
+}
+
+// IndexBatch indexes many documents at once, when rebuilding the index.
+func (b *BleveIndex) IndexBatch(docs []dto.SearchDocument) error {
+	batch := b.index.NewBatch()
+	for _, doc := range docs {
+		if err := batch.Index(docID(doc.Type, doc.ID), bleveFields(doc)); err != nil {
+			return err
+		}
+	}
+	return b.index.Batch(batch)
+}
+
+func (b *BleveIndex) Delete(ctx context.Context, docType string, id int64) error {
+	if err := b.index.Delete(docID(docType, id)); err != nil {
+		return err
+	}
+	if docType != dto.SearchTypePosts {
+		return nil
+	}
+
+	// The comments of the post were deleted along with it
+	q := bleve.NewBooleanQuery()
+	q.AddMust(termQuery("type", dto.SearchTypeComments), termQuery("post_id", strconv.FormatInt(id, 10)))
+
+	for {
+		res, err := b.index.SearchInContext(ctx, bleve.NewSearchRequestOptions(q, 500, 0, false))
+		if err != nil {
+			return err
+		}
+		if len(res.Hits) == 0 {
+			return nil
+		}
+
+		batch := b.index.NewBatch()
+		for _, hit := range res.Hits {
+			batch.Delete(hit.ID)
+		}
+		if err := b.index.Batch(batch); err != nil {
+			return err
+		}
+		if len(res.Hits) < 500 {
+			return nil
+		}
+	}
+}
+
+// Query runs the query in Bleve query string syntax: "phrases", +required, -excluded terms.
+func (b *BleveIndex) Query(ctx context.Context, viewerID int64, params dto.SearchQueryParams) ([]dto.SearchHit, error) {
+	if params.Type == dto.SearchTypeUsers {
+		return b.fallback.Query(ctx, viewerID, params)
+	}
+
+	match, err := bleve.NewQueryStringQuery(params.Query).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrInvalidQuery, err)
+	}
+
+	q := bleve.NewBooleanQuery()
+	q.AddMust(match, termQuery("type", params.Type))
+
+	blocked, err := b.blocks.GetBlockedUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range blocked {
+		userID := strconv.FormatInt(id, 10)
+		q.AddMustNot(termQuery("user_id", userID), termQuery("post_author_id", userID))
+	}
+
+	// Only public posts and those of the viewer are searched, followers-only posts would need
+	// the accounts the viewer follows in the query. Posts indexed before their visibility was
+	// recorded are found once the index is rebuilt with cmd/reindex.
+	if params.Type == dto.SearchTypePosts {
+		q.AddMust(bleve.NewDisjunctionQuery(
+			termQuery("visibility", dto.VisibilityPublic),
+			termQuery("user_id", strconv.FormatInt(viewerID, 10)),
+		))
+	}
+
+	if err := b.addContentFilters(ctx, q, viewerID, params.Type); err != nil {
+		return nil, err
+	}
+
+	req := bleve.NewSearchRequestOptions(q, params.Limit, (params.Page-1)*params.Limit, false)
+	req.Highlight = bleve.NewHighlightWithStyle(html.Name)
+	req.Highlight.AddField("content")
+
+	res, err := b.index.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		id, err := strconv.ParseInt(strings.TrimPrefix(hit.ID, params.Type+":"), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	hits := make([]dto.SearchHit, len(res.Hits))
+	for i, hit := range res.Hits {
+		hits[i] = dto.SearchHit{
+			Type:     params.Type,
+			ID:       ids[i],
+			Rank:     hit.Score,
+			Headline: strings.Join(hit.Fragments["content"], " … "),
+		}
+	}
+
+	if err := b.load(ctx, viewerID, params.Type, ids, hits); err != nil {
+		return nil, err
+	}
+
+	// Documents deleted from the store but not yet from the index are left out, as are those
+	// the viewer may no longer see
+	loaded := hits[:0]
+	for _, hit := range hits {
+		if hit.Post != nil || hit.Comment != nil {
+			loaded = append(loaded, hit)
+		}
+	}
+	return loaded, nil
+}
+
+func (b *BleveIndex) load(ctx context.Context, viewerID int64, docType string, ids []int64, hits []dto.SearchHit) error {
+	index := make(map[int64]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+
+	if docType == dto.SearchTypePosts {
+		posts, err := b.posts.GetByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, p := range posts {
+			if !searchable(viewerID, p.UserID, p.Visibility, p.Status) {
+				continue
+			}
+			p.Comments = []dto.Comment{}
+			hits[index[p.ID]].Post = &p
+		}
+		return nil
+	}
+
+	comments, err := b.comments.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, c := range comments {
+		if !searchable(viewerID, c.PostAuthorID, c.PostVisibility, c.PostStatus) {
+			continue
+		}
+		hits[index[c.ID]].Comment = &c.Comment
+	}
+	return nil
+}
+
+// searchable tells whether a post, or the comments of a post, may be found by the viewer: the
+// visibility of the post may have changed since it was indexed, and the author may comment on
+// their drafts.
+func searchable(viewerID, authorID int64, visibility, status string) bool {
+	if authorID == viewerID {
+		return true
+	}
+	return visibility == dto.VisibilityPublic && status == dto.PostStatusPublished
+}
+
+// addContentFilters leaves out the posts hidden by the content filters of the viewer, and the
+// comments containing their muted words. Muted words match as phrases of analyzed terms, so
+// only whole words, and regular expressions match single terms.
+func (b *BleveIndex) addContentFilters(ctx context.Context, q *query.BooleanQuery, viewerID int64, docType string) error {
+	filters, err := b.filters.GetActiveByUserID(ctx, viewerID)
+	if err != nil {
+		return err
+	}
+
+	fields := []string{"content"}
+	if docType == dto.SearchTypePosts {
+		fields = append(fields, "title")
+	}
+
+	var languages []query.Query
+	for _, f := range filters {
+		switch {
+		case f.Kind == dto.FilterKindWord:
+			for _, field := range fields {
+				if f.IsRegex {
+					// Patterns Postgres accepts but Go does not cannot be applied here
+					if _, err := regexp.Compile(f.Value); err != nil {
+						continue
+					}
+					rq := bleve.NewRegexpQuery(f.Value)
+					rq.SetField(field)
+					q.AddMustNot(rq)
+				} else {
+					phrase := bleve.NewMatchPhraseQuery(f.Value)
+					phrase.SetField(field)
+					q.AddMustNot(phrase)
+				}
+			}
+		case f.Kind == dto.FilterKindTag && docType == dto.SearchTypePosts:
+			q.AddMustNot(termQuery("tags", f.Value))
+		case f.Kind == dto.FilterKindLanguage && docType == dto.SearchTypePosts:
+			languages = append(languages, termQuery("language", f.Value))
+		}
+	}
+
+	if len(languages) > 0 {
+		q.AddMust(bleve.NewDisjunctionQuery(append(languages, termQuery("language", noLanguage))...))
+	}
+
+	return nil
+}
+
+// Close releases the index, which is locked while open.
+func (b *BleveIndex) Close() error {
+	return b.index.Close()
+}
+
+func termQuery(field, term string) query.Query {
+	q := bleve.NewTermQuery(term)
+	q.SetField(field)
+	return q
+}
@@ -0,0 +1,30 @@
+package search
+
+import (
+	"context"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/interfaces"
+)
+
+// PostgresIndex searches with Postgres full-text search. The search vectors are maintained by
+// triggers, so Index and Delete have nothing to do.
+type PostgresIndex struct {
+	store interfaces.SearchInterface
+}
+
+func NewPostgresIndex(store interfaces.SearchInterface) *PostgresIndex {
+	return &PostgresIndex{store: store}
+}
+
+func (p *PostgresIndex) Index(ctx context.Context, doc dto.SearchDocument) error {
+	return nil
+}
+
+func (p *PostgresIndex) Delete(ctx context.Context, docType string, id int64) error {
+	return nil
+}
+
+func (p *PostgresIndex) Query(ctx context.Context, viewerID int64, params dto.SearchQueryParams) ([]dto.SearchHit, error) {
+	return p.store.Search(ctx, viewerID, params)
+}
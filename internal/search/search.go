@@ -0,0 +1,36 @@
+// Package search implements the search backends: Postgres full-text search, and an embedded
+// Bleve index for deployments that keep search off the database.
+package search
+
+import (
+	"github.com/mafi020/social/internal/dto"
+)
+
+const (
+	BackendPostgres = "postgres"
+	BackendBleve    = "bleve"
+)
+
+func PostDocument(p dto.Post) dto.SearchDocument {
+	return dto.SearchDocument{
+		Type:       dto.SearchTypePosts,
+		ID:         p.ID,
+		UserID:     p.UserID,
+		Title:      p.Title,
+		Content:    p.Content,
+		Tags:       p.Tags,
+		Language:   p.Language,
+		Visibility: p.Visibility,
+	}
+}
+
+func CommentDocument(c dto.Comment, postAuthorID int64) dto.SearchDocument {
+	return dto.SearchDocument{
+		Type:         dto.SearchTypeComments,
+		ID:           c.ID,
+		UserID:       c.UserID,
+		PostID:       c.PostID,
+		PostAuthorID: postAuthorID,
+		Content:      c.Content,
+	}
+}
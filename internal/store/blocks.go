@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// notBlocked matches when neither the viewer, expected as $1, nor the user of the column blocked
+// the other.
+func notBlocked(userColumn string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM user_blocks b
+		WHERE (b.user_id = $1 AND b.blocked_id = ` + userColumn + `)
+			OR (b.user_id = ` + userColumn + ` AND b.blocked_id = $1)
+	)`
+}
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block is idempotent, blocking a user twice is not an error.
+func (s *BlockStore) Block(ctx context.Context, userID, blockedID int64) error {
+	query := `
+		INSERT INTO user_blocks (user_id, blocked_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, blocked_id) DO NOTHING
+	`
+
+	_, err := s.db.ExecContext(ctx, query, userID, blockedID)
+	return err
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, userID, blockedID int64) error {
+	query := `DELETE FROM user_blocks WHERE user_id = $1 AND blocked_id = $2`
+
+	_, err := s.db.ExecContext(ctx, query, userID, blockedID)
+	return err
+}
+
+// Mute is idempotent, muting a user twice is not an error.
+func (s *BlockStore) Mute(ctx context.Context, userID, mutedID int64) error {
+	query := `
+		INSERT INTO user_mutes (user_id, muted_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, muted_id) DO NOTHING
+	`
+
+	_, err := s.db.ExecContext(ctx, query, userID, mutedID)
+	return err
+}
+
+func (s *BlockStore) Unmute(ctx context.Context, userID, mutedID int64) error {
+	query := `DELETE FROM user_mutes WHERE user_id = $1 AND muted_id = $2`
+
+	_, err := s.db.ExecContext(ctx, query, userID, mutedID)
+	return err
+}
+
+// GetBlockedUserIDs returns the users the viewer blocked and those who blocked the viewer.
+func (s *BlockStore) GetBlockedUserIDs(ctx context.Context, viewerID int64) ([]int64, error) {
+	query := `
+		SELECT blocked_id FROM user_blocks WHERE user_id = $1
+		UNION
+		SELECT user_id FROM user_blocks WHERE blocked_id = $1
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
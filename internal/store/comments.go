@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
+	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/db"
 	"github.com/mafi020/social/internal/dto"
 	"github.com/mafi020/social/internal/errs"
 )
@@ -15,21 +18,23 @@ type CommentStore struct {
 
 func (s *CommentStore) Create(ctx context.Context, comment *dto.Comment) error {
 	query := `
-		INSERT INTO comments(post_id, user_id, content)
-		VALUES($1, $2, $3) RETURNING id, post_id, user_id, content, created_at, updated_at
+		INSERT INTO comments(post_id, user_id, content, content_html)
+		VALUES($1, $2, $3, $4) RETURNING id, post_id, user_id, content, content_html, created_at, updated_at
 	`
 
-	err := s.db.QueryRowContext(
+	err := db.Conn(ctx, s.db).QueryRowContext(
 		ctx,
 		query,
 		&comment.PostID,
 		&comment.UserID,
 		&comment.Content,
+		&comment.ContentHTML,
 	).Scan(
 		&comment.ID,
 		&comment.PostID,
 		&comment.UserID,
 		&comment.Content,
+		&comment.ContentHTML,
 		&comment.CreatedAt,
 		&comment.UpdatedAt,
 	)
@@ -41,10 +46,10 @@ func (s *CommentStore) Create(ctx context.Context, comment *dto.Comment) error {
 }
 func (s *CommentStore) GetCommentsByPostID(ctx context.Context, postID int64) ([]dto.Comment, error) {
 	query := `
-		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, c.updated_at, u.id, u.username FROM comments c
+		SELECT c.id, c.post_id, c.user_id, c.content, c.content_html, c.created_at, c.updated_at, u.id, u.username FROM comments c
 		JOIN users u
 		ON u.id = c.user_id
-		WHERE c.post_id = $1
+		WHERE c.post_id = $1 AND c.deleted_at IS NULL
 		ORDER BY c.created_at DESC 
 	`
 
@@ -65,6 +70,7 @@ func (s *CommentStore) GetCommentsByPostID(ctx context.Context, postID int64) ([
 			&comment.PostID,
 			&comment.UserID,
 			&comment.Content,
+			&comment.ContentHTML,
 			&comment.CreatedAt,
 			&comment.UpdatedAt,
 			&comment.User.ID,
@@ -79,16 +85,20 @@ func (s *CommentStore) GetCommentsByPostID(ctx context.Context, postID int64) ([
 
 	return comments, nil
 }
+
+// GetByID returns the comment whatever the visibility of its post: unlike Posts.GetByID it
+// takes no viewer, so callers serving it must check that the viewer may read the post.
 func (s *CommentStore) GetByID(ctx context.Context, commentID int64) (*dto.Comment, error) {
 	query := `
-		SELECT id, post_id, user_id, content, created_at, updated_at
-		FROM comments
-		WHERE id=$1
+		SELECT c.id, c.post_id, c.user_id, c.content, c.content_html, c.created_at, c.updated_at
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.id=$1 AND c.deleted_at IS NULL AND p.deleted_at IS NULL
 	`
 
 	comment := &dto.Comment{}
 
-	err := s.db.QueryRowContext(ctx, query, commentID).Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, commentID).Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Content, &comment.ContentHTML, &comment.CreatedAt, &comment.UpdatedAt)
 
 	if err != nil {
 		switch {
@@ -103,20 +113,22 @@ func (s *CommentStore) GetByID(ctx context.Context, commentID int64) (*dto.Comme
 func (s *CommentStore) Update(ctx context.Context, comment *dto.Comment) error {
 	query := `
 		UPDATE comments
-		SET content=$1
-		WHERE id=$2
-		RETURNING id, post_id, user_id, content, created_at, updated_at
+		SET content=$1, content_html=$2
+		WHERE id=$3
+		RETURNING id, post_id, user_id, content, content_html, created_at, updated_at
 	`
-	err := s.db.QueryRowContext(
+	err := db.Conn(ctx, s.db).QueryRowContext(
 		ctx,
 		query,
 		comment.Content,
+		comment.ContentHTML,
 		comment.ID,
 	).Scan(
 		&comment.ID,
 		&comment.PostID,
 		&comment.UserID,
 		&comment.Content,
+		&comment.ContentHTML,
 		&comment.CreatedAt,
 		&comment.UpdatedAt,
 	)
@@ -126,13 +138,16 @@ func (s *CommentStore) Update(ctx context.Context, comment *dto.Comment) error {
 	}
 	return nil
 }
+
+// Delete moves the comment to the trash of its author, see Restore and Purge.
 func (s *CommentStore) Delete(ctx context.Context, commentID int64) error {
 	query := `
-		DELETE FROM comments
-		WHERE id=$1
+		UPDATE comments
+		SET deleted_at = NOW()
+		WHERE id=$1 AND deleted_at IS NULL
 	`
 
-	res, err := s.db.ExecContext(ctx, query, commentID)
+	res, err := db.Conn(ctx, s.db).ExecContext(ctx, query, commentID)
 	if err != nil {
 		return err
 	}
@@ -148,3 +163,245 @@ func (s *CommentStore) Delete(ctx context.Context, commentID int64) error {
 
 	return nil
 }
+
+// GetByIDs returns the comments with their author and the author of their post, in no
+// particular order. Unknown IDs and deleted comments, or comments of deleted posts, are ignored.
+func (s *CommentStore) GetByIDs(ctx context.Context, ids []int64) ([]dto.CommentWithPostAuthor, error) {
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.content, c.content_html, c.created_at, c.updated_at, u.id, u.username, p.user_id, p.visibility, p.status
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.id = ANY($1::bigint[]) AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+	`
+
+	return s.queryComments(ctx, query, pq.Array(ids))
+}
+
+// ListAfterID returns up to limit comments with an ID greater than afterID, by ID. It is used
+// to walk through every comment.
+func (s *CommentStore) ListAfterID(ctx context.Context, afterID int64, limit int) ([]dto.CommentWithPostAuthor, error) {
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.content, c.content_html, c.created_at, c.updated_at, u.id, u.username, p.user_id, p.visibility, p.status
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.id > $1 AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+		ORDER BY c.id
+		LIMIT $2
+	`
+
+	return s.queryComments(ctx, query, afterID, limit)
+}
+
+func (s *CommentStore) queryComments(ctx context.Context, query string, args ...any) ([]dto.CommentWithPostAuthor, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []dto.CommentWithPostAuthor{}
+	for rows.Next() {
+		var c dto.CommentWithPostAuthor
+		err := rows.Scan(
+			&c.ID,
+			&c.PostID,
+			&c.UserID,
+			&c.Content,
+			&c.ContentHTML,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+			&c.User.ID,
+			&c.User.UserName,
+			&c.PostAuthorID,
+			&c.PostVisibility,
+			&c.PostStatus,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+// GetLatestByPostIDs returns, in a single query, up to perPost of the latest comments of each
+// post, newest first within a post. Comments of users blocking or blocked by the viewer are
+// left out.
+func (s *CommentStore) GetLatestByPostIDs(ctx context.Context, viewerID int64, postIDs []int64, perPost int) ([]dto.Comment, error) {
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.content, c.content_html, c.created_at, c.updated_at, c.author_id, c.username
+		FROM unnest($2::bigint[]) AS ids(post_id)
+		CROSS JOIN LATERAL (
+			SELECT c.id, c.post_id, c.user_id, c.content, c.content_html, c.created_at, c.updated_at, u.id AS author_id, u.username
+			FROM comments c
+			JOIN users u ON u.id = c.user_id
+			WHERE c.post_id = ids.post_id
+				AND c.deleted_at IS NULL
+				AND ` + notBlocked("c.user_id") + `
+			ORDER BY c.created_at DESC, c.id DESC
+			LIMIT $3
+		) c
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(postIDs), perPost)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []dto.Comment{}
+	for rows.Next() {
+		var comment dto.Comment
+		err := rows.Scan(
+			&comment.ID,
+			&comment.PostID,
+			&comment.UserID,
+			&comment.Content,
+			&comment.ContentHTML,
+			&comment.CreatedAt,
+			&comment.UpdatedAt,
+			&comment.User.ID,
+			&comment.User.UserName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// GetDeletedByUserID returns the comments in the trash of the user, latest deleted first.
+func (s *CommentStore) GetDeletedByUserID(ctx context.Context, userID int64) ([]dto.Comment, error) {
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.content, c.content_html, c.created_at, c.updated_at, c.deleted_at, u.id, u.username
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.user_id = $1 AND c.deleted_at IS NOT NULL
+		ORDER BY c.deleted_at DESC, c.id DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []dto.Comment{}
+	for rows.Next() {
+		var comment dto.Comment
+		err := rows.Scan(
+			&comment.ID,
+			&comment.PostID,
+			&comment.UserID,
+			&comment.Content,
+			&comment.ContentHTML,
+			&comment.CreatedAt,
+			&comment.UpdatedAt,
+			&comment.DeletedAt,
+			&comment.User.ID,
+			&comment.User.UserName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// Restore takes the comment of the user out of the trash and returns it. The comment stays
+// hidden while its post is deleted.
+func (s *CommentStore) Restore(ctx context.Context, commentID, userID int64) (*dto.Comment, error) {
+	query := `
+		UPDATE comments
+		SET deleted_at = NULL
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		RETURNING id, post_id, user_id, content, content_html, created_at, updated_at
+	`
+
+	comment := &dto.Comment{}
+	err := db.Conn(ctx, s.db).QueryRowContext(ctx, query, commentID, userID).Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.UserID,
+		&comment.Content,
+		&comment.ContentHTML,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, errs.ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return comment, nil
+}
+
+// Purge deletes for good up to limit comments deleted before deletedBefore, and returns how
+// many were deleted.
+func (s *CommentStore) Purge(ctx context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM comments
+		WHERE id IN (
+			SELECT id FROM comments
+			WHERE deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+		)
+	`
+
+	res, err := db.Conn(ctx, s.db).ExecContext(ctx, query, deletedBefore, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// ListContentAfterID returns up to limit comments with an ID greater than afterID, by ID, with
+// only their ID, content and content HTML set. Unlike ListAfterID it walks through the trash
+// as well.
+func (s *CommentStore) ListContentAfterID(ctx context.Context, afterID int64, limit int) ([]dto.Comment, error) {
+	query := `
+		SELECT id, content, content_html
+		FROM comments
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []dto.Comment{}
+	for rows.Next() {
+		var comment dto.Comment
+		if err := rows.Scan(&comment.ID, &comment.Content, &comment.ContentHTML); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// SetContentHTML replaces the rendering of the content of the comment.
+func (s *CommentStore) SetContentHTML(ctx context.Context, commentID int64, html string) error {
+	query := `UPDATE comments SET content_html = $1 WHERE id = $2`
+
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, query, html, commentID)
+	return err
+}
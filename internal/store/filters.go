@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+)
+
+// activeFilters matches the content filters, aliased cf, of the user expected as $1 that have
+// not expired.
+const activeFilters = `cf.user_id = $1 AND (cf.expires_at IS NULL OR cf.expires_at > NOW())`
+
+// notFiltered matches the posts, aliased p, let through by the content filters of the user
+// expected as $1.
+const notFiltered = `
+	NOT EXISTS (
+		SELECT 1 FROM content_filters cf
+		WHERE ` + activeFilters + `
+			AND (
+				(cf.kind = 'word' AND (p.title || ' ' || p.content) ~* cf.pattern) OR
+				(cf.kind = 'tag' AND cf.value = ANY(p.tags))
+			)
+	)
+	AND (
+		p.language IS NULL
+		OR NOT EXISTS (
+			SELECT 1 FROM content_filters cf
+			WHERE ` + activeFilters + ` AND cf.kind = 'language'
+		)
+		OR EXISTS (
+			SELECT 1 FROM content_filters cf
+			WHERE ` + activeFilters + ` AND cf.kind = 'language' AND cf.value = p.language
+		)
+	)
+`
+
+// notMuted matches the text unless it contains one of the muted words of the user expected as
+// $1.
+func notMuted(text string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM content_filters cf
+		WHERE ` + activeFilters + `
+			AND cf.kind = 'word' AND ` + text + ` ~* cf.pattern
+	)`
+}
+
+// filterStatementTimeout bounds the statements evaluating notFiltered or notMuted, which match
+// the posts and comments against regular expressions of the viewer.
+const filterStatementTimeout = "2s"
+
+// withFilterTimeout runs fn in a transaction carried by the context, in which Postgres cancels
+// the statements running for longer than filterStatementTimeout. Queries of fn resolve their
+// connection with db.Conn. Within a transaction fn is run as is, the timeout would last until
+// the end of the outer transaction.
+func withFilterTimeout(ctx context.Context, conn *sql.DB, fn func(ctx context.Context) error) error {
+	if db.InTx(ctx) {
+		return fn(ctx)
+	}
+
+	return db.WithTx(ctx, conn, func(ctx context.Context) error {
+		if _, err := db.Conn(ctx, conn).ExecContext(ctx, `SET LOCAL statement_timeout = '`+filterStatementTimeout+`'`); err != nil {
+			return err
+		}
+		return fn(ctx)
+	})
+}
+
+const (
+	// maxPatternQuantifiers caps the quantifiers of the regular expressions of word filters.
+	maxPatternQuantifiers = 10
+	// maxPatternRepetition caps the bounds of the {m,n} repetitions, which Postgres expands.
+	maxPatternRepetition = 10
+)
+
+var (
+	patternQuantifier = regexp.MustCompile(`[*+?]|\{[0-9,]+\}`)
+	patternRepetition = regexp.MustCompile(`\{([0-9]*),?([0-9]*)\}`)
+	patternBackref    = regexp.MustCompile(`\\[1-9]`)
+)
+
+// checkPattern rejects with errs.ErrComplexPattern the regular expressions expensive to
+// evaluate: back-references, which Postgres matches by backtracking, large repetitions and
+// patterns with too many quantifiers.
+func checkPattern(pattern string) error {
+	if patternBackref.MatchString(pattern) {
+		return errs.ErrComplexPattern
+	}
+
+	if len(patternQuantifier.FindAllString(pattern, -1)) > maxPatternQuantifiers {
+		return errs.ErrComplexPattern
+	}
+
+	for _, bounds := range patternRepetition.FindAllStringSubmatch(pattern, -1) {
+		for _, bound := range bounds[1:] {
+			if bound == "" {
+				continue
+			}
+			if n, err := strconv.Atoi(bound); err != nil || n > maxPatternRepetition {
+				return errs.ErrComplexPattern
+			}
+		}
+	}
+
+	return nil
+}
+
+// filterPattern returns the regular expression matched by a word filter. Plain words match
+// anywhere in the text, \m and \M anchor whole words to word boundaries.
+func filterPattern(filter *dto.ContentFilter) string {
+	switch {
+	case filter.Kind != dto.FilterKindWord:
+		return ""
+	case filter.IsRegex:
+		return filter.Value
+	case filter.WholeWord:
+		return `\m` + regexp.QuoteMeta(filter.Value) + `\M`
+	default:
+		return regexp.QuoteMeta(filter.Value)
+	}
+}
+
+type ContentFilterStore struct {
+	db *sql.DB
+}
+
+// Create adds the filter, or replaces the options and expiry of the user's filter with the same
+// kind and value. Regular expressions are checked by Postgres, which evaluates them, and
+// rejected with errs.ErrInvalidPattern, or with errs.ErrComplexPattern when they are expensive
+// to evaluate (see checkPattern).
+func (s *ContentFilterStore) Create(ctx context.Context, filter *dto.ContentFilter) error {
+	filter.Pattern = filterPattern(filter)
+
+	if filter.IsRegex {
+		if err := checkPattern(filter.Pattern); err != nil {
+			return err
+		}
+	}
+
+	if filter.Pattern != "" {
+		var matches bool
+		if err := s.db.QueryRowContext(ctx, `SELECT '' ~* $1`, filter.Pattern).Scan(&matches); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "2201B" { // 2201B is invalid_regular_expression
+				return errs.ErrInvalidPattern
+			}
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO content_filters (user_id, kind, value, whole_word, is_regex, pattern, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, kind, value) DO UPDATE
+		SET whole_word = EXCLUDED.whole_word,
+			is_regex = EXCLUDED.is_regex,
+			pattern = EXCLUDED.pattern,
+			expires_at = EXCLUDED.expires_at
+		RETURNING id, created_at
+	`
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		filter.UserID,
+		filter.Kind,
+		filter.Value,
+		filter.WholeWord,
+		filter.IsRegex,
+		filter.Pattern,
+		filter.ExpiresAt,
+	).Scan(&filter.ID, &filter.CreatedAt)
+}
+
+// GetActiveByUserID returns the filters of the user that have not expired.
+func (s *ContentFilterStore) GetActiveByUserID(ctx context.Context, userID int64) ([]dto.ContentFilter, error) {
+	query := `
+		SELECT cf.id, cf.user_id, cf.kind, cf.value, cf.whole_word, cf.is_regex, cf.pattern, cf.expires_at, cf.created_at
+		FROM content_filters cf
+		WHERE ` + activeFilters + `
+		ORDER BY cf.kind, cf.value
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	filters := []dto.ContentFilter{}
+	for rows.Next() {
+		var f dto.ContentFilter
+		err := rows.Scan(
+			&f.ID,
+			&f.UserID,
+			&f.Kind,
+			&f.Value,
+			&f.WholeWord,
+			&f.IsRegex,
+			&f.Pattern,
+			&f.ExpiresAt,
+			&f.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+
+	return filters, rows.Err()
+}
+
+func (s *ContentFilterStore) Delete(ctx context.Context, userID, filterID int64) error {
+	query := `DELETE FROM content_filters WHERE id = $1 AND user_id = $2`
+
+	res, err := s.db.ExecContext(ctx, query, filterID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errs.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mafi020/social/internal/errs"
+)
+
+func TestCheckPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		err     error
+	}{
+		{`spoilers?`, nil},
+		{`\mfoo\M|bar+`, nil},
+		{`a{2,5}`, nil},
+		{`(a)\1`, errs.ErrComplexPattern},
+		{`a{1,1000}`, errs.ErrComplexPattern},
+		{`a{99999999999999999999}`, errs.ErrComplexPattern},
+		{`a*b*c*d*e*f*g*h*i*j*k*`, errs.ErrComplexPattern},
+	}
+
+	for _, tt := range tests {
+		if err := checkPattern(tt.pattern); !errors.Is(err, tt.err) {
+			t.Errorf("checkPattern(%q) = %v, want %v", tt.pattern, err, tt.err)
+		}
+	}
+}
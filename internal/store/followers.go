@@ -7,6 +7,7 @@ import (
 	"log"
 
 	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/db"
 	"github.com/mafi020/social/internal/errs"
 )
 
@@ -19,7 +20,7 @@ func (s *FollowerStore) Follow(ctx context.Context, userID, followerID int64) er
 		INSERT INTO followers(user_id, follower_id)
 		VALUES ($1, $2)
 	`
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, query, userID, followerID)
 
 	if err != nil {
 		log.Printf("Error %d\n", err)
@@ -37,10 +38,36 @@ func (s *FollowerStore) UnFollow(ctx context.Context, userIDToUnfollow, follower
 		DELETE FROM followers
 		WHERE user_id = $1 AND follower_id = $2
 	`
-	_, err := s.db.ExecContext(ctx, query, userIDToUnfollow, followerID)
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, query, userIDToUnfollow, followerID)
 
 	if err != nil {
 		return err
 	}
 	return nil
 }
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `
+		SELECT follower_id
+		FROM followers
+		WHERE user_id = $1
+	`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/mafi020/social/internal/db"
 	"github.com/mafi020/social/internal/dto"
 	"github.com/mafi020/social/internal/errs"
 )
@@ -20,7 +21,7 @@ func (s *InvitationStore) Create(ctx context.Context, inv *dto.Invitation) error
 		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at
 	`
-	err := s.db.QueryRowContext(
+	err := db.Conn(ctx, s.db).QueryRowContext(
 		ctx,
 		query,
 		inv.InviterID,
@@ -114,12 +115,12 @@ func (s *InvitationStore) GetByToken(ctx context.Context, token string) (*dto.In
 }
 
 func (s *InvitationStore) UpdateStatus(ctx context.Context, id int64, status string) error {
-	_, err := s.db.ExecContext(ctx, `UPDATE invitations SET status=$1 WHERE id=$2`, status, id)
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, `UPDATE invitations SET status=$1 WHERE id=$2`, status, id)
 	return err
 }
 
 func (s *InvitationStore) UpdateEmailStatus(ctx context.Context, id int64, emailSentAt *time.Time) error {
-	_, err := s.db.ExecContext(ctx, `UPDATE invitations SET email_sent_at=$1 WHERE id=$2`, emailSentAt, id)
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, `UPDATE invitations SET email_sent_at=$1 WHERE id=$2`, emailSentAt, id)
 	return err
 }
 
@@ -130,7 +131,7 @@ func (s *InvitationStore) Update(ctx context.Context, inv *dto.Invitation) error
 		WHERE id = $3
 		RETURNING id, inviter_id, email, token, status, expires_at, created_at, updated_at
 	`
-	err := s.db.QueryRowContext(
+	err := db.Conn(ctx, s.db).QueryRowContext(
 		ctx,
 		query,
 		inv.Status,
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+)
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+func (s *MediaStore) Create(ctx context.Context, m *dto.Media) error {
+	query := `
+		INSERT INTO media (user_id, storage_key, thumbnail_key, mime_type, size, width, height, alt_text)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		m.UserID,
+		m.StorageKey,
+		m.ThumbnailKey,
+		m.MimeType,
+		m.Size,
+		m.Width,
+		m.Height,
+		m.AltText,
+	).Scan(&m.ID, &m.CreatedAt)
+}
+
+// Attach makes the media of the user, in order, the attachments of the post. The previous
+// attachments left out are detached, to be removed by the media cleaner. Media of other users
+// or attached to another post fail with errs.ErrInvalidMedia.
+func (s *MediaStore) Attach(ctx context.Context, postID, userID int64, ids []int64) error {
+	return db.WithTx(ctx, s.db, func(ctx context.Context) error {
+		conn := db.Conn(ctx, s.db)
+
+		detach := `UPDATE media SET post_id = NULL WHERE post_id = $1 AND NOT (id = ANY($2::bigint[]))`
+		if _, err := conn.ExecContext(ctx, detach, postID, pq.Array(ids)); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		attach := `
+			UPDATE media m
+			SET post_id = $1, position = a.ord - 1
+			FROM unnest($2::bigint[]) WITH ORDINALITY AS a(id, ord)
+			WHERE m.id = a.id AND m.user_id = $3 AND (m.post_id IS NULL OR m.post_id = $1)
+		`
+		res, err := conn.ExecContext(ctx, attach, postID, pq.Array(ids), userID)
+		if err != nil {
+			return err
+		}
+
+		attached, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if attached != int64(len(ids)) {
+			return errs.ErrInvalidMedia
+		}
+
+		return nil
+	})
+}
+
+// GetByPostIDs returns the attachments of the posts, ordered by post and position.
+func (s *MediaStore) GetByPostIDs(ctx context.Context, postIDs []int64) ([]dto.Media, error) {
+	query := `
+		SELECT ` + mediaColumns + `
+		FROM media
+		WHERE post_id = ANY($1::bigint[])
+		ORDER BY post_id, position
+	`
+
+	return s.queryMedia(ctx, query, pq.Array(postIDs))
+}
+
+// GetUnattached returns up to limit media uploaded before createdBefore and attached to no post.
+func (s *MediaStore) GetUnattached(ctx context.Context, createdBefore time.Time, limit int) ([]dto.Media, error) {
+	query := `
+		SELECT ` + mediaColumns + `
+		FROM media
+		WHERE post_id IS NULL AND created_at < $1
+		ORDER BY created_at
+		LIMIT $2
+	`
+
+	return s.queryMedia(ctx, query, createdBefore, limit)
+}
+
+// Delete deletes the media, unless they have been attached meanwhile, and returns the IDs of
+// the deleted ones.
+func (s *MediaStore) Delete(ctx context.Context, ids []int64) ([]int64, error) {
+	query := `DELETE FROM media WHERE id = ANY($1::bigint[]) AND post_id IS NULL RETURNING id`
+
+	rows, err := db.Conn(ctx, s.db).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deleted := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, id)
+	}
+
+	return deleted, rows.Err()
+}
+
+const mediaColumns = `id, user_id, post_id, position, storage_key, thumbnail_key, mime_type, size, width, height,
+	alt_text, created_at`
+
+func (s *MediaStore) queryMedia(ctx context.Context, query string, args ...any) ([]dto.Media, error) {
+	rows, err := db.Conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := []dto.Media{}
+	for rows.Next() {
+		var m dto.Media
+		err := rows.Scan(
+			&m.ID,
+			&m.UserID,
+			&m.PostID,
+			&m.Position,
+			&m.StorageKey,
+			&m.ThumbnailKey,
+			&m.MimeType,
+			&m.Size,
+			&m.Width,
+			&m.Height,
+			&m.AltText,
+			&m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+
+	return media, rows.Err()
+}
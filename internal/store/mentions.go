@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+)
+
+type MentionStore struct {
+	db *sql.DB
+}
+
+// Set replaces the users, in order, mentioned by the post, or by its comment when commentID is
+// set. It returns the users that were not mentioned before.
+func (s *MentionStore) Set(ctx context.Context, postID int64, commentID *int64, userIDs []int64) ([]int64, error) {
+	query := `
+		WITH previous AS (
+			DELETE FROM mentions
+			WHERE post_id = $1 AND comment_id IS NOT DISTINCT FROM $2::bigint
+			RETURNING user_id
+		), inserted AS (
+			INSERT INTO mentions (post_id, comment_id, user_id, position)
+			SELECT $1, $2::bigint, m.user_id, m.ord - 1
+			FROM unnest($3::bigint[]) WITH ORDINALITY AS m(user_id, ord)
+			RETURNING user_id
+		)
+		SELECT user_id FROM inserted
+		WHERE user_id NOT IN (SELECT user_id FROM previous)
+	`
+
+	rows, err := db.Conn(ctx, s.db).QueryContext(ctx, query, postID, commentID, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	added := []int64{}
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		added = append(added, userID)
+	}
+
+	return added, rows.Err()
+}
+
+// GetByPostIDs returns the mentions of the posts, leaving out the ones of their comments,
+// ordered by post and position.
+func (s *MentionStore) GetByPostIDs(ctx context.Context, postIDs []int64) ([]dto.Mention, error) {
+	query := `
+		SELECT m.post_id, m.comment_id, u.id, u.username
+		FROM mentions m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.post_id = ANY($1::bigint[]) AND m.comment_id IS NULL
+		ORDER BY m.post_id, m.position
+	`
+
+	return s.queryMentions(ctx, query, pq.Array(postIDs))
+}
+
+// GetByCommentIDs returns the mentions of the comments, ordered by comment and position.
+func (s *MentionStore) GetByCommentIDs(ctx context.Context, commentIDs []int64) ([]dto.Mention, error) {
+	query := `
+		SELECT m.post_id, m.comment_id, u.id, u.username
+		FROM mentions m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.comment_id = ANY($1::bigint[])
+		ORDER BY m.comment_id, m.position
+	`
+
+	return s.queryMentions(ctx, query, pq.Array(commentIDs))
+}
+
+func (s *MentionStore) queryMentions(ctx context.Context, query string, args ...any) ([]dto.Mention, error) {
+	rows, err := db.Conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []dto.Mention{}
+	for rows.Next() {
+		var m dto.Mention
+		if err := rows.Scan(&m.PostID, &m.CommentID, &m.UserID, &m.UserName); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+
+	return mentions, rows.Err()
+}
+
+// GetNotifiable returns the users, among userIDs, to notify of a mention by the actor in the
+// post or one of its comments: users who may read the published post, other than the actor,
+// and who neither block, are blocked by, nor mute the actor.
+func (s *MentionStore) GetNotifiable(ctx context.Context, actorID, postID int64, userIDs []int64) ([]int64, error) {
+	query := `
+		SELECT u.id
+		FROM users u
+		JOIN posts p ON p.id = $2
+		WHERE u.id = ANY($3::bigint[])
+			AND u.id <> $1
+			AND p.status = 'published'
+			AND p.deleted_at IS NULL
+			AND (
+				p.user_id = u.id OR
+				p.visibility IN ('public', 'unlisted') OR
+				(p.visibility = 'followers' AND EXISTS (
+					SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = u.id
+				))
+			)
+			AND ` + notBlocked("u.id") + `
+			AND NOT EXISTS (
+				SELECT 1 FROM user_mutes m
+				WHERE m.user_id = u.id AND m.muted_id = $1
+			)
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, actorID, postID, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifiable := []int64{}
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		notifiable = append(notifiable, userID)
+	}
+
+	return notifiable, rows.Err()
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+)
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+func (s *NotificationStore) Create(ctx context.Context, n *dto.Notification) error {
+	query := `
+		INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id, data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	data := []byte(n.Data)
+	if len(data) == 0 {
+		data = []byte("{}")
+	}
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		n.UserID,
+		n.ActorID,
+		n.Type,
+		n.PostID,
+		n.CommentID,
+		data,
+	).Scan(&n.ID, &n.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetByUserID returns the newest notifications first. Pagination is keyset based on the
+// notification id: pass the id of the last notification of the previous page as the cursor.
+func (s *NotificationStore) GetByUserID(ctx context.Context, userID int64, params dto.NotificationQueryParams) ([]dto.Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.actor_id, n.type, n.post_id, n.comment_id, n.data, n.read_at, n.created_at, u.id, u.username
+		FROM notifications n
+		LEFT JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = $1
+			AND ($2::bigint = 0 OR n.id < $2::bigint)
+			AND (NOT $3::boolean OR n.read_at IS NULL)
+		ORDER BY n.id DESC
+		LIMIT $4
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, params.Cursor, params.UnreadOnly, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []dto.Notification{}
+	for rows.Next() {
+		var n dto.Notification
+		var data []byte
+		var actorID sql.NullInt64
+		var actorUserName sql.NullString
+
+		err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&n.ActorID,
+			&n.Type,
+			&n.PostID,
+			&n.CommentID,
+			&data,
+			&n.ReadAt,
+			&n.CreatedAt,
+			&actorID,
+			&actorUserName,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		n.Data = data
+		if actorID.Valid {
+			n.Actor = &dto.CommentUser{ID: actorID.Int64, UserName: actorUserName.String}
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (s *NotificationStore) MarkRead(ctx context.Context, userID, notificationID int64) error {
+	query := `
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2
+	`
+
+	res, err := s.db.ExecContext(ctx, query, notificationID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errs.ErrNotFound
+	}
+
+	return nil
+}
+
+// MarkAllRead marks every unread notification of the user as read and returns how many changed.
+func (s *NotificationStore) MarkAllRead(ctx context.Context, userID int64) (int64, error) {
+	query := `
+		UPDATE notifications
+		SET read_at = NOW()
+		WHERE user_id = $1 AND read_at IS NULL
+	`
+
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *NotificationStore) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM notifications
+		WHERE user_id = $1 AND read_at IS NULL
+	`
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
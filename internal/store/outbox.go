@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+)
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+// Insert joins the transaction carried by the context, which is what makes the outbox
+// transactional: the event is stored if and only if the write that produced it commits.
+func (s *OutboxStore) Insert(ctx context.Context, name string, payload []byte) error {
+	query := `INSERT INTO outbox_events (name, payload) VALUES ($1, $2)`
+
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, query, name, payload)
+	return err
+}
+
+// ClaimPending picks events ready to be dispatched and hides them from other relays for the
+// duration of the lease.
+func (s *OutboxStore) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]dto.OutboxEvent, error) {
+	query := `
+		UPDATE outbox_events
+		SET available_at = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id
+			FROM outbox_events
+			WHERE processed_at IS NULL AND available_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, name, payload, attempts, created_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []dto.OutboxEvent{}
+	for rows.Next() {
+		var e dto.OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Name, &payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *OutboxStore) MarkProcessed(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE outbox_events SET processed_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// MarkFailed schedules a retry, or gives up on the event when retryAt is nil.
+func (s *OutboxStore) MarkFailed(ctx context.Context, id int64, attempts int, lastError string, retryAt *time.Time) error {
+	query := `
+		UPDATE outbox_events
+		SET attempts = $1,
+			last_error = $2,
+			available_at = COALESCE($3, available_at),
+			processed_at = CASE WHEN $3::timestamptz IS NULL THEN NOW() END
+		WHERE id = $4
+	`
+
+	_, err := s.db.ExecContext(ctx, query, attempts, lastError, retryAt, id)
+	return err
+}
+
+// GetHandlers returns the names of the handlers that handled the event.
+func (s *OutboxStore) GetHandlers(ctx context.Context, id int64) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT handler FROM outbox_event_handlers WHERE event_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	handlers := []string{}
+	for rows.Next() {
+		var handler string
+		if err := rows.Scan(&handler); err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, handler)
+	}
+
+	return handlers, rows.Err()
+}
+
+// MarkHandled records that the handler handled the event, for retries to skip it.
+func (s *OutboxStore) MarkHandled(ctx context.Context, id int64, handler string) error {
+	query := `
+		INSERT INTO outbox_event_handlers (event_id, handler)
+		VALUES ($1, $2)
+		ON CONFLICT (event_id, handler) DO NOTHING
+	`
+
+	_, err := s.db.ExecContext(ctx, query, id, handler)
+	return err
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/db"
 	"github.com/mafi020/social/internal/dto"
 	"github.com/mafi020/social/internal/errs"
 )
@@ -17,25 +20,39 @@ type PostStore struct {
 
 func (s *PostStore) Create(ctx context.Context, post *dto.Post) error {
 	query := `
-		INSERT INTO posts (title, content, user_id, tags)
-		VALUES ($1, $2, $3, $4) RETURNING id, title, content, user_id, tags, created_at, updated_at
+		INSERT INTO posts (title, content, content_html, user_id, tags, language, visibility, status, publish_at, quote_of_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10)
+		RETURNING id, title, content, content_html, user_id, tags, COALESCE(language, ''), visibility, status, publish_at, created_at, updated_at, edited_at, quote_of_id
 	`
 
-	err := s.db.QueryRowContext(
+	err := db.Conn(ctx, s.db).QueryRowContext(
 		ctx,
 		query,
 		post.Title,
 		post.Content,
+		post.ContentHTML,
 		post.UserID,
 		pq.Array(post.Tags),
+		post.Language,
+		post.Visibility,
+		post.Status,
+		post.PublishAt,
+		post.QuoteOfID,
 	).Scan(
 		&post.ID,
 		&post.Title,
 		&post.Content,
+		&post.ContentHTML,
 		&post.UserID,
 		pq.Array(&post.Tags),
+		&post.Language,
+		&post.Visibility,
+		&post.Status,
+		&post.PublishAt,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.EditedAt,
+		&post.QuoteOfID,
 	)
 
 	if err != nil {
@@ -44,27 +61,42 @@ func (s *PostStore) Create(ctx context.Context, post *dto.Post) error {
 
 	return nil
 }
-func (s *PostStore) GetByID(ctx context.Context, postID int64) (*dto.Post, error) {
+
+// GetByID returns the post if the viewer may read it (see readablePost), errs.ErrForbidden
+// otherwise. Unpublished posts are only readable by their author, deleted posts are not found.
+func (s *PostStore) GetByID(ctx context.Context, postID, viewerID int64) (*dto.Post, error) {
 	query := `
-		SELECT id, title, content, user_id, tags, created_at, updated_at
-		FROM posts
-		WHERE id = $1
+		SELECT p.id, p.title, p.content, p.content_html, p.user_id, p.tags, COALESCE(p.language, ''), p.visibility, p.status, p.publish_at,
+			p.created_at, p.updated_at, p.edited_at, p.repost_of_id, p.quote_of_id, ` + readablePost + ` AS readable
+		FROM posts p
+		WHERE p.id = $2 AND p.deleted_at IS NULL
 	`
 
 	post := &dto.Post{}
+	var readable bool
 
 	err := s.db.QueryRowContext(
 		ctx,
 		query,
+		viewerID,
 		postID,
 	).Scan(
 		&post.ID,
 		&post.Title,
 		&post.Content,
+		&post.ContentHTML,
 		&post.UserID,
 		pq.Array(&post.Tags),
+		&post.Language,
+		&post.Visibility,
+		&post.Status,
+		&post.PublishAt,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.EditedAt,
+		&post.RepostOfID,
+		&post.QuoteOfID,
+		&readable,
 	)
 
 	if err != nil {
@@ -76,87 +108,66 @@ func (s *PostStore) GetByID(ctx context.Context, postID int64) (*dto.Post, error
 		}
 	}
 
-	return post, nil
-}
-func (s *PostStore) GetAll(ctx context.Context) ([]dto.Post, error) {
-	query := `
-		SELECT id, title, content, user_id, tags, created_at, updated_at
-		FROM posts
-		ORDER BY created_at DESC
-	`
-
-	rows, err := s.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
+	if !readable {
+		return nil, errs.ErrForbidden
 	}
-	defer rows.Close()
-
-	var posts []dto.Post
 
-	for rows.Next() {
-		var post dto.Post
-		err := rows.Scan(
-			&post.ID,
-			&post.Title,
-			&post.Content,
-			&post.UserID,
-			pq.Array(&post.Tags),
-			&post.CreatedAt,
-			&post.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		posts = append(posts, post)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return posts, nil
+	return post, nil
 }
-func (s *PostStore) Delete(ctx context.Context, postId int64) error {
-	query := `DELETE FROM posts WHERE id = $1`
 
-	res, err := s.db.ExecContext(ctx, query, postId)
-	if err != nil {
-		return err
-	}
+// Delete moves the post to the trash of its author, see Restore and Purge, and returns the ID
+// of the author. Its comments are hidden along with it.
+func (s *PostStore) Delete(ctx context.Context, postId int64) (int64, error) {
+	query := `UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING user_id`
 
-	rowsAffected, err := res.RowsAffected()
+	var userID int64
+	err := db.Conn(ctx, s.db).QueryRowContext(ctx, query, postId).Scan(&userID)
 	if err != nil {
-		return err
-	}
-
-	if rowsAffected == 0 {
-		return errs.ErrNotFound
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, errs.ErrNotFound
+		default:
+			return 0, err
+		}
 	}
 
-	return nil
+	return userID, nil
 }
 func (s *PostStore) Update(ctx context.Context, post *dto.Post) error {
 	query := `
 		UPDATE posts
-		SET title = $1, content = $2, tags = $3
-		WHERE id = $4
-		RETURNING id, title, content, tags, user_id, created_at, updated_at
+		SET title = $1, content = $2, content_html = $3, tags = $4, language = NULLIF($5, ''), visibility = $6,
+			status = $7, publish_at = $8,
+			created_at = CASE WHEN status <> 'published' AND $7 = 'published' THEN NOW() ELSE created_at END
+		WHERE id = $9
+		RETURNING id, title, content, content_html, tags, COALESCE(language, ''), visibility, status, publish_at, user_id, created_at, updated_at, edited_at
 	`
-	err := s.db.QueryRowContext(
+	err := db.Conn(ctx, s.db).QueryRowContext(
 		ctx,
 		query,
 		post.Title,
 		post.Content,
+		post.ContentHTML,
 		pq.Array(post.Tags),
+		post.Language,
+		post.Visibility,
+		post.Status,
+		post.PublishAt,
 		post.ID,
 	).Scan(
 		&post.ID,
 		&post.Title,
 		&post.Content,
+		&post.ContentHTML,
 		pq.Array(&post.Tags),
+		&post.Language,
+		&post.Visibility,
+		&post.Status,
+		&post.PublishAt,
 		&post.UserID,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.EditedAt,
 	)
 
 	if err != nil {
@@ -164,37 +175,112 @@ func (s *PostStore) Update(ctx context.Context, post *dto.Post) error {
 	}
 	return nil
 }
-func (s *PostStore) Feed(ctx context.Context, userID int64, params dto.FeedQueryParams) ([]dto.Feed, int, error) {
-	log.Printf("Params %v", params)
-	countQuery := `
-		SELECT COUNT(DISTINCT p.id)
-		FROM posts p
-		JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
-		WHERE (f.user_id = $1 OR p.user_id = $1)
+
+// readablePost matches the posts, aliased p, the user expected as $1 may read: their own, and
+// published public and unlisted posts, and followers-only posts of the accounts they follow.
+const readablePost = `(
+	p.user_id = $1 OR
+	(p.status = 'published' AND (
+		p.visibility IN ('public', 'unlisted') OR
+		(p.visibility = 'followers' AND EXISTS (
+			SELECT 1 FROM followers vf WHERE vf.user_id = p.user_id AND vf.follower_id = $1
+		))
+	))
+)`
+
+// listedPost matches the published, not deleted, readable posts listed in explore, tag timelines and search,
+// which leaves out unlisted posts of other users, and reposts.
+const listedPost = `(
+	p.status = 'published' AND p.deleted_at IS NULL AND p.repost_of_id IS NULL AND (
+		p.user_id = $1 OR
+		p.visibility = 'public' OR
+		(p.visibility = 'followers' AND EXISTS (
+			SELECT 1 FROM followers vf WHERE vf.user_id = p.user_id AND vf.follower_id = $1
+		))
+	)
+)`
+
+// feedSource lists the posts of the home feed of the user, expected as $1: their timeline,
+// plus the posts of the user and of the accounts they follow that were not fanned out (see
+// TimelineStore.FanOut). keysetOp, when set, compares (created_at, id) with ($5, $6).
+func feedSource(keysetOp string) string {
+	timelineKeyset, pulledKeyset := "", ""
+	if keysetOp != "" {
+		timelineKeyset = "AND (t.created_at, t.post_id) " + keysetOp + " ($5, $6)"
+		pulledKeyset = "AND (fp.created_at, fp.id) " + keysetOp + " ($5, $6)"
+	}
+
+	return `(
+		SELECT t.post_id AS id
+		FROM timelines t
+		WHERE t.user_id = $1 ` + timelineKeyset + `
+		UNION ALL
+		SELECT fp.id
+		FROM posts fp
+		WHERE fp.fanned_out_at IS NULL
 			AND (
-				$2 = '' OR
-				p.title ILIKE '%' || $2 || '%' OR
-				p.content ILIKE '%' || $2 || '%'
-			)
+				fp.user_id = $1 OR
+				fp.user_id IN (SELECT f.user_id FROM followers f WHERE f.follower_id = $1)
+			) ` + pulledKeyset + `
+	) AS feed_ids
+	JOIN posts p ON p.id = feed_ids.id`
+}
+
+// availableRepost matches the posts, aliased p, that are not reposts, and the reposts whose
+// original the user expected as $1 may still read (see readablePost): a repost leaves the feeds
+// along with its original.
+var availableRepost = `(
+	p.repost_of_id IS NULL OR EXISTS (
+		SELECT 1 FROM posts op
+		WHERE op.id = p.repost_of_id
+			AND op.status = 'published'
+			AND op.deleted_at IS NULL
 			AND (
-				cardinality($3::text[]) = 0 OR
-				EXISTS (
-					SELECT 1 FROM unnest($3::text[]) AS tag 
-					WHERE tag = ANY(p.tags)
-				)
+				op.user_id = $1 OR
+				op.visibility IN ('public', 'unlisted') OR
+				(op.visibility = 'followers' AND EXISTS (
+					SELECT 1 FROM followers vf WHERE vf.user_id = op.user_id AND vf.follower_id = $1
+				))
 			)
+			AND ` + notBlocked("op.user_id") + `
+	)
+)`
 
+// feedFilter matches the search (full-text, websearch syntax) and tags filters, expected as $2
+// and $3, and leaves out unpublished and deleted posts, the posts the user expected as $1 may
+// not read or hid with their content filters, and the reposts of posts no longer available.
+var feedFilter = `
+	p.status = 'published'
+	AND p.deleted_at IS NULL
+	AND (
+		$2 = '' OR
+		p.search_vector @@ websearch_to_tsquery('english', $2)
+	)
+	AND (
+		cardinality($3::varchar[]) = 0 OR
+		p.tags && $3::varchar[]
+	)
+	AND ` + readablePost + `
+	AND ` + availableRepost + `
+	AND ` + notFiltered
 
-	`
+const feedColumns = `
+	p.id, p.user_id, p.title, p.content, p.content_html, p.tags, COALESCE(p.language, ''), p.visibility, p.status, p.created_at, p.updated_at, p.edited_at,
+	p.repost_of_id, p.quote_of_id,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments_count,
+	u.id, u.username
+`
 
-	var totalCount int
-	var tagsParam any
-	if len(params.Tags) == 0 {
-		tagsParam = pq.Array([]string{})
-	} else {
-		tagsParam = pq.Array(params.Tags)
+func feedTags(tags []string) any {
+	if len(tags) == 0 {
+		return pq.Array([]string{})
 	}
-	err := s.db.QueryRowContext(ctx, countQuery, userID, params.Search, tagsParam).Scan(&totalCount)
+	return pq.Array(tags)
+}
+
+// Feed returns a page of the feed using LIMIT/OFFSET, along with the total count.
+func (s *PostStore) Feed(ctx context.Context, userID int64, params dto.FeedQueryParams) ([]dto.Feed, int, error) {
+	totalCount, err := s.CountFeed(ctx, userID, params, 0)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -202,60 +288,671 @@ func (s *PostStore) Feed(ctx context.Context, userID int64, params dto.FeedQuery
 	offset := (params.Page - 1) * params.Limit
 
 	query := `
-        SELECT
-            p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at,
-            COUNT(c.id) AS comments_count,
-            u.id, u.username
-        FROM posts p
-        LEFT JOIN comments c ON c.post_id = p.id
-        LEFT JOIN users u ON u.id = p.user_id
-        JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $3
-			WHERE 
-			(f.user_id = $3 OR p.user_id = $3)
-			AND (
-				$4 = '' OR
-				p.title ILIKE '%' || $4 || '%' OR
-				p.content ILIKE '%' || $4 || '%'
-			)
-			AND (
-				cardinality($5::text[]) = 0 OR
-				EXISTS (
-					SELECT 1 FROM unnest($5::text[]) AS tag 
-					WHERE tag = ANY(p.tags)
-				)
-			)
-        GROUP BY p.id, u.id, u.username
-        ORDER BY p.created_at DESC
-        LIMIT $1 OFFSET $2
-    `
+		SELECT ` + feedColumns + `
+		FROM ` + feedSource("") + `
+		JOIN users u ON u.id = p.user_id
+		WHERE ` + feedFilter + `
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $4 OFFSET $5
+	`
 
-	rows, err := s.db.QueryContext(ctx, query, params.Limit, offset, userID, params.Search, tagsParam)
+	feed, err := s.queryFeed(ctx, scanFeed, query, userID, params.Search, feedTags(params.Tags), params.Limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
-	defer rows.Close()
 
+	if err := s.embedFeedOriginals(ctx, userID, feed); err != nil {
+		return nil, 0, err
+	}
+
+	return feed, totalCount, nil
+}
+
+// FeedByCursor returns the posts following params.Cursor (or the newest ones without a cursor),
+// newest first. The boolean reports whether more posts exist past the page in the direction
+// of the pagination.
+func (s *PostStore) FeedByCursor(ctx context.Context, userID int64, params dto.FeedQueryParams) ([]dto.Feed, bool, error) {
+	keysetOp, order := "", "DESC"
+	args := []any{userID, params.Search, feedTags(params.Tags), params.Limit + 1}
+
+	if params.Cursor != nil {
+		keysetOp = "<"
+		if params.Backward {
+			keysetOp, order = ">", "ASC"
+		}
+		args = append(args, params.Cursor.CreatedAt, params.Cursor.ID)
+	}
+
+	query := `
+		SELECT ` + feedColumns + `
+		FROM ` + feedSource(keysetOp) + `
+		JOIN users u ON u.id = p.user_id
+		WHERE ` + feedFilter + `
+		ORDER BY p.created_at ` + order + `, p.id ` + order + `
+		LIMIT $4
+	`
+
+	feed, err := s.queryFeed(ctx, scanFeed, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(feed) > params.Limit
+	if hasMore {
+		feed = feed[:params.Limit]
+	}
+
+	if params.Backward {
+		slices.Reverse(feed)
+	}
+
+	if err := s.embedFeedOriginals(ctx, userID, feed); err != nil {
+		return nil, false, err
+	}
+
+	return feed, hasMore, nil
+}
+
+// Explore returns the posts of every user, newest first, paginated like FeedByCursor. Posts
+// of authors the viewer blocked or muted, or who blocked the viewer, are left out. With
+// params.Tag set only the posts carrying that tag are returned.
+func (s *PostStore) Explore(ctx context.Context, viewerID int64, params dto.FeedQueryParams) ([]dto.Feed, bool, error) {
+	args := []any{viewerID, params.Search, feedTags(params.Tags), params.Limit + 1}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions, order := "", "DESC"
+	if params.Tag != "" {
+		conditions += " AND p.tags @> ARRAY[" + arg(params.Tag) + "::varchar]"
+	}
+	if params.Cursor != nil {
+		op := "<"
+		if params.Backward {
+			op, order = ">", "ASC"
+		}
+		conditions += " AND (p.created_at, p.id) " + op + " (" + arg(params.Cursor.CreatedAt) + ", " + arg(params.Cursor.ID) + ")"
+	}
+
+	query := `
+		SELECT ` + feedColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE ` + feedFilter + `
+			AND ` + listedPost + `
+			AND ` + notBlocked("p.user_id") + `
+			AND NOT EXISTS (
+				SELECT 1 FROM user_mutes m
+				WHERE m.user_id = $1 AND m.muted_id = p.user_id
+			)` + conditions + `
+		ORDER BY p.created_at ` + order + `, p.id ` + order + `
+		LIMIT $4
+	`
+
+	feed, err := s.queryFeed(ctx, scanFeed, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(feed) > params.Limit
+	if hasMore {
+		feed = feed[:params.Limit]
+	}
+
+	if params.Backward {
+		slices.Reverse(feed)
+	}
+
+	if err := s.embedFeedOriginals(ctx, viewerID, feed); err != nil {
+		return nil, false, err
+	}
+
+	return feed, hasMore, nil
+}
+
+// CountFeed counts the posts of the feed, ignoring the cursor. With max > 0 counting stops at
+// max, which keeps it cheap for users with a large feed.
+func (s *PostStore) CountFeed(ctx context.Context, userID int64, params dto.FeedQueryParams, max int) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM (
+			SELECT 1
+			FROM ` + feedSource("") + `
+			WHERE ` + feedFilter + `
+			LIMIT NULLIF($4::int, 0)
+		) AS feed
+	`
+
+	var count int
+	err := withFilterTimeout(ctx, s.db, func(ctx context.Context) error {
+		return db.Conn(ctx, s.db).QueryRowContext(ctx, query, userID, params.Search, feedTags(params.Tags), max).Scan(&count)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// TopFeed returns a page of the posts published within the ranking window, by descending score,
+// along with their total count. Each post carries its score.
+//
+// The score adds up, weighted:
+//   - recency: 1 for a new post, halved every HalfLifeHours
+//   - comments: ln(1 + comments)
+//   - reactions: ln(1 + reactions, of any type)
+//   - affinity: ln(1 + comments of the user on the author's posts over the last 90 days)
+func (s *PostStore) TopFeed(ctx context.Context, userID int64, params dto.FeedQueryParams, ranking dto.FeedRanking) ([]dto.Feed, int, error) {
+	window := `p.created_at > NOW() - make_interval(hours => $4::int)`
+
+	countQuery := `
+		SELECT COUNT(*)
+		FROM ` + feedSource("") + `
+		WHERE ` + feedFilter + ` AND ` + window
+
+	var totalCount int
+	err := withFilterTimeout(ctx, s.db, func(ctx context.Context) error {
+		return db.Conn(ctx, s.db).QueryRowContext(ctx, countQuery, userID, params.Search, feedTags(params.Tags), ranking.WindowHours).Scan(&totalCount)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		WITH signals AS (
+			SELECT
+				p.id, p.user_id, p.title, p.content, p.content_html, p.tags, COALESCE(p.language, '') AS language, p.visibility, p.status, p.created_at, p.updated_at, p.edited_at,
+				p.repost_of_id, p.quote_of_id, u.username,
+				(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments_count,
+				power(0.5, EXTRACT(EPOCH FROM NOW() - p.created_at)::float8 / 3600 / $5::float8) AS recency,
+				(SELECT COUNT(*) FROM reactions r WHERE r.post_id = p.id)::float8 AS reactions,
+				CASE WHEN p.user_id = $1 THEN 0 ELSE (
+					SELECT COUNT(*)
+					FROM comments c
+					JOIN posts ap ON ap.id = c.post_id
+					WHERE c.user_id = $1
+						AND c.deleted_at IS NULL
+						AND ap.user_id = p.user_id
+						AND c.created_at > NOW() - INTERVAL '90 days'
+				) END AS interactions
+			FROM ` + feedSource("") + `
+			JOIN users u ON u.id = p.user_id
+			WHERE ` + feedFilter + ` AND ` + window + `
+		), scored AS (
+			SELECT
+				signals.*,
+				$6::float8 * recency AS recency_score,
+				$7::float8 * ln(1 + comments_count::float8) AS comments_score,
+				$8::float8 * ln(1 + reactions) AS reactions_score,
+				$9::float8 * ln(1 + interactions::float8) AS affinity_score
+			FROM signals
+		)
+		SELECT
+			id, user_id, title, content, content_html, tags, language, visibility, status, created_at, updated_at, edited_at,
+			repost_of_id, quote_of_id, comments_count,
+			user_id, username,
+			recency_score, comments_score, reactions_score, affinity_score
+		FROM scored
+		ORDER BY recency_score + comments_score + reactions_score + affinity_score DESC, id DESC
+		LIMIT $10 OFFSET $11
+	`
+
+	offset := (params.Page - 1) * params.Limit
+
+	feed, err := s.queryFeed(ctx, scanScoredFeed, query,
+		userID, params.Search, feedTags(params.Tags), ranking.WindowHours, ranking.HalfLifeHours,
+		ranking.RecencyWeight, ranking.CommentsWeight, ranking.ReactionsWeight, ranking.AffinityWeight,
+		params.Limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.embedFeedOriginals(ctx, userID, feed); err != nil {
+		return nil, 0, err
+	}
+
+	return feed, totalCount, nil
+}
+
+// scanScoredFeed scans the posts of TopFeed along with their score.
+func scanScoredFeed(rows *sql.Rows) ([]dto.Feed, error) {
 	var feed []dto.Feed
 	for rows.Next() {
 		var f dto.Feed
+		var score dto.FeedScore
 		err := rows.Scan(
 			&f.ID,
 			&f.UserID,
 			&f.Title,
 			&f.Content,
+			&f.ContentHTML,
 			pq.Array(&f.Tags),
+			&f.Language,
+			&f.Visibility,
+			&f.Status,
 			&f.CreatedAt,
 			&f.UpdatedAt,
+			&f.EditedAt,
+			&f.RepostOfID,
+			&f.QuoteOfID,
 			&f.CommentsCount,
 			&f.User.ID,
 			&f.User.UserName,
+			&score.Recency,
+			&score.Comments,
+			&score.Reactions,
+			&score.Affinity,
 		)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
+		score.Total = score.Recency + score.Comments + score.Reactions + score.Affinity
+		f.Score = &score
 		f.Comments = []dto.Comment{}
 		feed = append(feed, f)
 	}
 
-	return feed, totalCount, nil
+	return feed, rows.Err()
+}
+
+// queryFeed runs a query of the feed, scanned by scan, within the filterStatementTimeout of the
+// content filters it evaluates.
+func (s *PostStore) queryFeed(ctx context.Context, scan func(*sql.Rows) ([]dto.Feed, error), query string, args ...any) ([]dto.Feed, error) {
+	var feed []dto.Feed
+	err := withFilterTimeout(ctx, s.db, func(ctx context.Context) error {
+		rows, err := db.Conn(ctx, s.db).QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		feed, err = scan(rows)
+		return err
+	})
+	return feed, err
+}
+
+func scanFeed(rows *sql.Rows) ([]dto.Feed, error) {
+	var feed []dto.Feed
+	for rows.Next() {
+		var f dto.Feed
+		err := rows.Scan(
+			&f.ID,
+			&f.UserID,
+			&f.Title,
+			&f.Content,
+			&f.ContentHTML,
+			pq.Array(&f.Tags),
+			&f.Language,
+			&f.Visibility,
+			&f.Status,
+			&f.CreatedAt,
+			&f.UpdatedAt,
+			&f.EditedAt,
+			&f.RepostOfID,
+			&f.QuoteOfID,
+			&f.CommentsCount,
+			&f.User.ID,
+			&f.User.UserName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		f.Comments = []dto.Comment{}
+		feed = append(feed, f)
+	}
+
+	return feed, rows.Err()
+}
+
+// EmbedOriginals sets the originals of the reposts and quote posts among the posts, with a
+// single query. Originals the viewer may not read (see readablePost), unpublished or deleted
+// ones, and those of users blocking or blocked by the viewer are left unset.
+func (s *PostStore) EmbedOriginals(ctx context.Context, viewerID int64, posts ...*dto.Post) error {
+	var ids []int64
+	for _, p := range posts {
+		if p.RepostOfID != nil {
+			ids = append(ids, *p.RepostOfID)
+		}
+		if p.QuoteOfID != nil {
+			ids = append(ids, *p.QuoteOfID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = ANY($2::bigint[])
+			AND p.status = 'published'
+			AND p.deleted_at IS NULL
+			AND ` + readablePost + `
+			AND ` + notBlocked("p.user_id")
+
+	originals, err := s.queryPosts(ctx, query, viewerID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int64]*dto.Post, len(originals))
+	for i := range originals {
+		originals[i].Comments = []dto.Comment{}
+		byID[originals[i].ID] = &originals[i]
+	}
+	for _, p := range posts {
+		if p.RepostOfID != nil {
+			p.RepostOf = byID[*p.RepostOfID]
+		}
+		if p.QuoteOfID != nil {
+			p.QuoteOf = byID[*p.QuoteOfID]
+		}
+	}
+
+	return nil
+}
+
+func (s *PostStore) embedFeedOriginals(ctx context.Context, viewerID int64, feed []dto.Feed) error {
+	posts := make([]*dto.Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+	return s.EmbedOriginals(ctx, viewerID, posts...)
+}
+
+// Repost makes the user repost the post, which the caller made sure is public: reposts are
+// public too, and leave the feeds once their original is no longer readable (see
+// availableRepost). Reposting a post twice returns the existing repost, with created false,
+// and restores it from the trash.
+func (s *PostStore) Repost(ctx context.Context, userID, postID int64) (repost *dto.Post, created bool, err error) {
+	conn := db.Conn(ctx, s.db)
+
+	query := `
+		INSERT INTO posts (title, content, content_html, user_id, tags, visibility, status, repost_of_id)
+		VALUES ('', '', '', $1, '{}', 'public', 'published', $2)
+		ON CONFLICT (user_id, repost_of_id) WHERE repost_of_id IS NOT NULL DO NOTHING
+		RETURNING id
+	`
+
+	var repostID int64
+	created = true
+	err = conn.QueryRowContext(ctx, query, userID, postID).Scan(&repostID)
+	if errors.Is(err, sql.ErrNoRows) {
+		created = false
+		query = `UPDATE posts SET deleted_at = NULL WHERE user_id = $1 AND repost_of_id = $2 RETURNING id`
+		err = conn.QueryRowContext(ctx, query, userID, postID).Scan(&repostID)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	posts, err := s.GetByIDs(ctx, []int64{repostID})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(posts) == 0 {
+		return nil, false, errs.ErrNotFound
+	}
+
+	return &posts[0], created, nil
+}
+
+// DeleteRepost deletes the repost of the post by the user for good, reposts have nothing worth
+// keeping in the trash. It returns the ID of the repost, errs.ErrNotFound if there is none.
+func (s *PostStore) DeleteRepost(ctx context.Context, userID, postID int64) (int64, error) {
+	query := `DELETE FROM posts WHERE user_id = $1 AND repost_of_id = $2 RETURNING id`
+
+	var repostID int64
+	err := db.Conn(ctx, s.db).QueryRowContext(ctx, query, userID, postID).Scan(&repostID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, errs.ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return repostID, nil
+}
+
+// GetByIDs returns the posts with their author, in no particular order. Unknown IDs and deleted
+// posts are ignored.
+func (s *PostStore) GetByIDs(ctx context.Context, ids []int64) ([]dto.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = ANY($1::bigint[]) AND p.deleted_at IS NULL
+	`
+
+	return s.queryPosts(ctx, query, pq.Array(ids))
+}
+
+// ListAfterID returns up to limit posts with an ID greater than afterID, by ID, deleted posts
+// left out. It is used to walk through every post.
+func (s *PostStore) ListAfterID(ctx context.Context, afterID int64, limit int) ([]dto.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id > $1 AND p.deleted_at IS NULL
+		ORDER BY p.id
+		LIMIT $2
+	`
+
+	return s.queryPosts(ctx, query, afterID, limit)
+}
+
+// GetLatestByUserID returns the latest public posts of the user, reposts left out, newest first.
+func (s *PostStore) GetLatestByUserID(ctx context.Context, userID int64, limit int) ([]dto.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.user_id = $1 AND p.status = 'published' AND p.visibility = 'public' AND p.deleted_at IS NULL
+			AND p.repost_of_id IS NULL
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2
+	`
+
+	return s.queryPosts(ctx, query, userID, limit)
+}
+
+// GetLatestByTag returns the latest public posts carrying the tag, newest first.
+func (s *PostStore) GetLatestByTag(ctx context.Context, tag string, limit int) ([]dto.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.tags @> ARRAY[$1::varchar] AND p.status = 'published' AND p.visibility = 'public'
+			AND p.deleted_at IS NULL
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2
+	`
+
+	return s.queryPosts(ctx, query, tag, limit)
+}
+
+// GetUnpublishedByUserID returns the drafts and scheduled posts of the user, scheduled posts
+// first by publication time, then drafts by last update.
+func (s *PostStore) GetUnpublishedByUserID(ctx context.Context, userID int64) ([]dto.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.user_id = $1 AND p.status <> 'published' AND p.deleted_at IS NULL
+		ORDER BY p.publish_at ASC NULLS LAST, p.updated_at DESC, p.id DESC
+	`
+
+	return s.queryPosts(ctx, query, userID)
+}
+
+// PublishDue publishes up to limit scheduled posts whose publication time has come, and returns
+// them. Posts are locked until the transaction the call takes part in ends, so concurrent
+// schedulers publish each post once.
+func (s *PostStore) PublishDue(ctx context.Context, limit int) ([]dto.Post, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM posts
+			WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+			ORDER BY publish_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), published AS (
+			UPDATE posts
+			SET status = 'published', created_at = NOW()
+			FROM due
+			WHERE posts.id = due.id
+			RETURNING posts.*
+		)
+		SELECT ` + postColumns + `
+		FROM published p
+		JOIN users u ON u.id = p.user_id
+		ORDER BY p.publish_at, p.id
+	`
+
+	return s.queryPosts(ctx, query, limit)
+}
+
+// postColumns are the columns scanned by queryPosts, posts aliased p joined to their author u.
+const postColumns = `p.id, p.title, p.content, p.content_html, p.user_id, p.tags, COALESCE(p.language, ''), p.visibility, p.status,
+	p.publish_at, p.created_at, p.updated_at, p.edited_at, p.deleted_at, p.repost_of_id, p.quote_of_id, u.id, u.username`
+
+func (s *PostStore) queryPosts(ctx context.Context, query string, args ...any) ([]dto.Post, error) {
+	rows, err := db.Conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []dto.Post{}
+	for rows.Next() {
+		var post dto.Post
+		err := rows.Scan(
+			&post.ID,
+			&post.Title,
+			&post.Content,
+			&post.ContentHTML,
+			&post.UserID,
+			pq.Array(&post.Tags),
+			&post.Language,
+			&post.Visibility,
+			&post.Status,
+			&post.PublishAt,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.EditedAt,
+			&post.DeletedAt,
+			&post.RepostOfID,
+			&post.QuoteOfID,
+			&post.User.ID,
+			&post.User.UserName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
+// GetDeletedByUserID returns the posts in the trash of the user, latest deleted first.
+func (s *PostStore) GetDeletedByUserID(ctx context.Context, userID int64) ([]dto.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.user_id = $1 AND p.deleted_at IS NOT NULL
+		ORDER BY p.deleted_at DESC, p.id DESC
+	`
+
+	return s.queryPosts(ctx, query, userID)
+}
+
+// Restore takes the post of the user out of the trash, along with its comments, and returns it.
+func (s *PostStore) Restore(ctx context.Context, postID, userID int64) (*dto.Post, error) {
+	query := `
+		WITH restored AS (
+			UPDATE posts
+			SET deleted_at = NULL
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+			RETURNING posts.*
+		)
+		SELECT ` + postColumns + `
+		FROM restored p
+		JOIN users u ON u.id = p.user_id
+	`
+
+	posts, err := s.queryPosts(ctx, query, postID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, errs.ErrNotFound
+	}
+
+	return &posts[0], nil
+}
+
+// Purge deletes for good up to limit posts deleted before deletedBefore, with their comments,
+// and returns how many were deleted.
+func (s *PostStore) Purge(ctx context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM posts
+		WHERE id IN (
+			SELECT id FROM posts
+			WHERE deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+		)
+	`
+
+	res, err := db.Conn(ctx, s.db).ExecContext(ctx, query, deletedBefore, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// ListContentAfterID returns up to limit posts with an ID greater than afterID, by ID, with only
+// their ID, content and content HTML set. Unlike ListAfterID it walks through the trash as well.
+func (s *PostStore) ListContentAfterID(ctx context.Context, afterID int64, limit int) ([]dto.Post, error) {
+	query := `
+		SELECT id, content, content_html
+		FROM posts
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []dto.Post{}
+	for rows.Next() {
+		var post dto.Post
+		if err := rows.Scan(&post.ID, &post.Content, &post.ContentHTML); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
+// SetContentHTML replaces the rendering of the content of the post, without editing it.
+func (s *PostStore) SetContentHTML(ctx context.Context, postID int64, html string) error {
+	query := `UPDATE posts SET content_html = $1 WHERE id = $2`
+
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, query, html, postID)
+	return err
 }
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+)
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// reactionColumn returns the column of the reactions referencing the target.
+func reactionColumn(target string) (string, error) {
+	switch target {
+	case dto.ReactionTargetPost:
+		return "post_id", nil
+	case dto.ReactionTargetComment:
+		return "comment_id", nil
+	default:
+		return "", fmt.Errorf("unknown reaction target %q", target)
+	}
+}
+
+// Set makes the type the reaction of the user to the post or comment, replacing their previous
+// reaction. Setting the same reaction again changes nothing.
+func (s *ReactionStore) Set(ctx context.Context, target string, targetID, userID int64, reactionType string) error {
+	column, err := reactionColumn(target)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO reactions (` + column + `, user_id, type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (` + column + `, user_id) WHERE ` + column + ` IS NOT NULL
+		DO UPDATE SET type = EXCLUDED.type, created_at = NOW()
+		WHERE reactions.type <> EXCLUDED.type
+	`
+
+	_, err = db.Conn(ctx, s.db).ExecContext(ctx, query, targetID, userID, reactionType)
+	return err
+}
+
+// Delete removes the reaction of the user to the post or comment, if any.
+func (s *ReactionStore) Delete(ctx context.Context, target string, targetID, userID int64) error {
+	column, err := reactionColumn(target)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM reactions WHERE ` + column + ` = $1 AND user_id = $2`
+
+	_, err = db.Conn(ctx, s.db).ExecContext(ctx, query, targetID, userID)
+	return err
+}
+
+// GetCounts returns the number of reactions of each type to the posts or comments, with
+// whether the viewer made them, in no particular order.
+func (s *ReactionStore) GetCounts(ctx context.Context, target string, targetIDs []int64, viewerID int64) ([]dto.ReactionCount, error) {
+	column, err := reactionColumn(target)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + column + `, type, COUNT(*), bool_or(user_id = $2)
+		FROM reactions
+		WHERE ` + column + ` = ANY($1::bigint[])
+		GROUP BY ` + column + `, type
+	`
+
+	rows, err := db.Conn(ctx, s.db).QueryContext(ctx, query, pq.Array(targetIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []dto.ReactionCount{}
+	for rows.Next() {
+		var c dto.ReactionCount
+		if err := rows.Scan(&c.TargetID, &c.Type, &c.Count, &c.ViewerReacted); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// GetByTarget returns who reacted to the post or comment, latest first. Pagination is keyset
+// based on the reaction id, as for notifications. Users blocking or blocked by the viewer are
+// left out.
+func (s *ReactionStore) GetByTarget(ctx context.Context, target string, targetID, viewerID int64, params dto.ReactionQueryParams) ([]dto.Reaction, error) {
+	column, err := reactionColumn(target)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT r.id, r.type, r.created_at, u.id, u.username
+		FROM reactions r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.` + column + ` = $2
+			AND ($3::bigint = 0 OR r.id < $3::bigint)
+			AND ($4::text = '' OR r.type = $4::text)
+			AND ` + notBlocked("r.user_id") + `
+		ORDER BY r.id DESC
+		LIMIT $5
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, targetID, params.Cursor, params.Type, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := []dto.Reaction{}
+	for rows.Next() {
+		var r dto.Reaction
+		if err := rows.Scan(&r.ID, &r.Type, &r.CreatedAt, &r.User.ID, &r.User.UserName); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, r)
+	}
+
+	return reactions, rows.Err()
+}
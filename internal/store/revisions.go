@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+)
+
+type PostRevisionStore struct {
+	db *sql.DB
+}
+
+// Create records the current title, content and tags of the post as its next revision, unless
+// they are those of the latest revision. It is called after every write of a post, in the same
+// transaction.
+func (s *PostRevisionStore) Create(ctx context.Context, postID, editorID int64) error {
+	query := `
+		WITH latest AS (
+			SELECT revision, title, content, tags
+			FROM post_revisions
+			WHERE post_id = $1
+			ORDER BY revision DESC
+			LIMIT 1
+		)
+		INSERT INTO post_revisions (post_id, revision, title, content, tags, editor_id)
+		SELECT p.id, COALESCE((SELECT revision FROM latest), 0) + 1, p.title, p.content, COALESCE(p.tags, '{}'), $2
+		FROM posts p
+		WHERE p.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM latest l
+			WHERE l.title = p.title AND l.content = p.content AND l.tags = COALESCE(p.tags, '{}')
+		)
+	`
+
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, query, postID, editorID)
+	return err
+}
+
+// GetByPostID returns the revisions of the post, latest first.
+func (s *PostRevisionStore) GetByPostID(ctx context.Context, postID int64) ([]dto.PostRevision, error) {
+	query := `
+		SELECT post_id, revision, title, content, tags, editor_id, created_at
+		FROM post_revisions
+		WHERE post_id = $1
+		ORDER BY revision DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []dto.PostRevision{}
+	for rows.Next() {
+		var r dto.PostRevision
+		err := rows.Scan(
+			&r.PostID,
+			&r.Revision,
+			&r.Title,
+			&r.Content,
+			pq.Array(&r.Tags),
+			&r.EditorID,
+			&r.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s *PostRevisionStore) GetByRevision(ctx context.Context, postID int64, revision int) (*dto.PostRevision, error) {
+	query := `
+		SELECT post_id, revision, title, content, tags, editor_id, created_at
+		FROM post_revisions
+		WHERE post_id = $1 AND revision = $2
+	`
+
+	r := &dto.PostRevision{}
+	err := s.db.QueryRowContext(ctx, query, postID, revision).Scan(
+		&r.PostID,
+		&r.Revision,
+		&r.Title,
+		&r.Content,
+		pq.Array(&r.Tags),
+		&r.EditorID,
+		&r.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, errs.ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
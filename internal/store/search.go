@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+)
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+const headlineOptions = "MaxFragments=2, MaxWords=30, MinWords=10, StartSel=<mark>, StopSel=</mark>"
+
+// escapedHTML escapes the markdown of the column for HTML before it is headlined, so the
+// <mark> tags wrapping the matches are the only markup of the headlines.
+func escapedHTML(column string) string {
+	return `replace(replace(replace(replace(replace(` + column + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// Search runs a full-text search in websearch_to_tsquery syntax, ranked by ts_rank. Content
+// of users who blocked the viewer, or whom the viewer blocked, is left out, as are posts not
+// listed for the viewer (see listedPost) and their comments, posts hidden by the content filters
+// of the viewer and comments containing their muted words.
+func (s *SearchStore) Search(ctx context.Context, viewerID int64, params dto.SearchQueryParams) ([]dto.SearchHit, error) {
+	offset := (params.Page - 1) * params.Limit
+
+	switch params.Type {
+	case dto.SearchTypePosts:
+		return s.searchPosts(ctx, viewerID, params.Query, params.Limit, offset)
+	case dto.SearchTypeComments:
+		return s.searchComments(ctx, viewerID, params.Query, params.Limit, offset)
+	case dto.SearchTypeUsers:
+		return s.searchUsers(ctx, viewerID, params.Query, params.Limit, offset)
+	default:
+		return nil, fmt.Errorf("unknown search type %q", params.Type)
+	}
+}
+
+func (s *SearchStore) searchPosts(ctx context.Context, viewerID int64, q string, limit, offset int) ([]dto.SearchHit, error) {
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_html, p.tags, COALESCE(p.language, ''), p.visibility, p.status, p.created_at, p.updated_at, p.edited_at,
+			u.id, u.username,
+			ts_rank(p.search_vector, q) AS rank,
+			ts_headline('english', ` + escapedHTML("p.content") + `, q, $5)
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		CROSS JOIN websearch_to_tsquery('english', $2) AS q
+		WHERE p.search_vector @@ q
+			AND ` + listedPost + `
+			AND ` + notBlocked("p.user_id") + `
+			AND ` + notFiltered + `
+		ORDER BY rank DESC, p.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	hits := []dto.SearchHit{}
+	err := withFilterTimeout(ctx, s.db, func(ctx context.Context) error {
+		rows, err := db.Conn(ctx, s.db).QueryContext(ctx, query, viewerID, q, limit, offset, headlineOptions)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			post := &dto.Post{}
+			hit := dto.SearchHit{Type: dto.SearchTypePosts, Post: post}
+			err := rows.Scan(
+				&post.ID,
+				&post.UserID,
+				&post.Title,
+				&post.Content,
+				&post.ContentHTML,
+				pq.Array(&post.Tags),
+				&post.Language,
+				&post.Visibility,
+				&post.Status,
+				&post.CreatedAt,
+				&post.UpdatedAt,
+				&post.EditedAt,
+				&post.User.ID,
+				&post.User.UserName,
+				&hit.Rank,
+				&hit.Headline,
+			)
+			if err != nil {
+				return err
+			}
+			hit.ID = post.ID
+			post.Comments = []dto.Comment{}
+			hits = append(hits, hit)
+		}
+
+		return rows.Err()
+	})
+
+	return hits, err
+}
+
+func (s *SearchStore) searchComments(ctx context.Context, viewerID int64, q string, limit, offset int) ([]dto.SearchHit, error) {
+	query := `
+		SELECT
+			c.id, c.post_id, c.user_id, c.content, c.content_html, c.created_at, c.updated_at,
+			u.id, u.username,
+			ts_rank(c.search_vector, q) AS rank,
+			ts_headline('english', ` + escapedHTML("c.content") + `, q, $5)
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		JOIN posts p ON p.id = c.post_id
+		CROSS JOIN websearch_to_tsquery('english', $2) AS q
+		WHERE c.search_vector @@ q
+			AND c.deleted_at IS NULL
+			AND ` + notBlocked("c.user_id") + `
+			AND ` + listedPost + `
+			AND ` + notBlocked("p.user_id") + `
+			AND ` + notMuted("c.content") + `
+		ORDER BY rank DESC, c.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	hits := []dto.SearchHit{}
+	err := withFilterTimeout(ctx, s.db, func(ctx context.Context) error {
+		rows, err := db.Conn(ctx, s.db).QueryContext(ctx, query, viewerID, q, limit, offset, headlineOptions)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			comment := &dto.Comment{}
+			hit := dto.SearchHit{Type: dto.SearchTypeComments, Comment: comment}
+			err := rows.Scan(
+				&comment.ID,
+				&comment.PostID,
+				&comment.UserID,
+				&comment.Content,
+				&comment.ContentHTML,
+				&comment.CreatedAt,
+				&comment.UpdatedAt,
+				&comment.User.ID,
+				&comment.User.UserName,
+				&hit.Rank,
+				&hit.Headline,
+			)
+			if err != nil {
+				return err
+			}
+			hit.ID = comment.ID
+			hits = append(hits, hit)
+		}
+
+		return rows.Err()
+	})
+
+	return hits, err
+}
+
+func (s *SearchStore) searchUsers(ctx context.Context, viewerID int64, q string, limit, offset int) ([]dto.SearchHit, error) {
+	query := `
+		SELECT u.id, u.username, ts_rank(u.search_vector, q) AS rank
+		FROM users u
+		CROSS JOIN websearch_to_tsquery('simple', $2) AS q
+		WHERE u.search_vector @@ q
+			AND ` + notBlocked("u.id") + `
+		ORDER BY rank DESC, u.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []dto.SearchHit{}
+	for rows.Next() {
+		user := &dto.CommentUser{}
+		hit := dto.SearchHit{Type: dto.SearchTypeUsers, User: user}
+		if err := rows.Scan(&user.ID, &user.UserName, &hit.Rank); err != nil {
+			return nil, err
+		}
+		hit.ID = user.ID
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// Reindex recomputes the search vectors of every row, after the text search configuration of
+// the triggers changed.
+func (s *SearchStore) Reindex(ctx context.Context) error {
+	for _, query := range []string{
+		`UPDATE posts SET title = title`,
+		`UPDATE comments SET content = content`,
+		`UPDATE users SET username = username`,
+	} {
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
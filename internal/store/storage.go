@@ -1,27 +1,63 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 
+	"github.com/mafi020/social/internal/db"
 	"github.com/mafi020/social/internal/interfaces"
 )
 
 type Storage struct {
+	db *sql.DB
+
 	Posts         interfaces.PostsInterface
 	Users         interfaces.UsersInterface
 	Comments      interfaces.CommentsInterface
 	Followers     interfaces.FollowersInterface
 	Invitations   interfaces.InvitationInterface
 	RefreshTokens interfaces.RefreshTokensInterface
+	Notifications interfaces.NotificationsInterface
+	Webhooks      interfaces.WebhooksInterface
+	Outbox        interfaces.OutboxInterface
+	Timelines     interfaces.TimelinesInterface
+	Blocks        interfaces.BlocksInterface
+	Tags          interfaces.TagsInterface
+	Search        interfaces.SearchInterface
+	Filters       interfaces.ContentFiltersInterface
+	Revisions     interfaces.PostRevisionsInterface
+	Media         interfaces.MediaInterface
+	Mentions      interfaces.MentionsInterface
+	Reactions     interfaces.ReactionsInterface
 }
 
 func NewPostgresStorage(db *sql.DB) Storage {
 	return Storage{
+		db: db,
+
 		Posts:         &PostStore{db},
 		Users:         &UserStore{db},
 		Comments:      &CommentStore{db},
 		Followers:     &FollowerStore{db},
 		Invitations:   &InvitationStore{db},
 		RefreshTokens: &RefreshTokensStore{db},
+		Notifications: &NotificationStore{db},
+		Webhooks:      &WebhookStore{db},
+		Outbox:        &OutboxStore{db},
+		Timelines:     &TimelineStore{db},
+		Blocks:        &BlockStore{db},
+		Tags:          &TagStore{db},
+		Search:        &SearchStore{db},
+		Filters:       &ContentFilterStore{db},
+		Revisions:     &PostRevisionStore{db},
+		Media:         &MediaStore{db},
+		Mentions:      &MentionStore{db},
+		Reactions:     &ReactionStore{db},
 	}
 }
+
+// WithTx runs fn in a transaction. Store calls made with the context passed to fn take part in
+// it when the store supports it (see db.Conn).
+func (s Storage) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.WithTx(ctx, s.db, fn)
+}
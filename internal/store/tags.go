@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+)
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// Trending ranks the tags used at least minPosts times over the window by how much their use
+// exceeds their baseline, measured over the baseline period preceding the window. The score
+// is (posts - expected) / sqrt(expected + 1) so that tags without history need a few posts
+// before they trend. Denied tags are left out. Tags are compared as stored, normalized by
+// utils.NormalizeTag.
+func (s *TagStore) Trending(ctx context.Context, window, baseline time.Duration, minPosts, limit int) ([]dto.TrendingTag, error) {
+	query := `
+		WITH tag_posts AS (
+			SELECT t.tag, p.created_at
+			FROM posts p
+			CROSS JOIN LATERAL unnest(p.tags) AS t(tag)
+			WHERE p.created_at > NOW() - make_interval(secs => $1::float8 + $2::float8)
+				AND p.status = 'published'
+				AND p.visibility = 'public'
+				AND p.deleted_at IS NULL
+		), counts AS (
+			SELECT
+				tag,
+				COUNT(*) FILTER (WHERE created_at > NOW() - make_interval(secs => $1::float8)) AS posts,
+				COUNT(*) FILTER (WHERE created_at <= NOW() - make_interval(secs => $1::float8)) AS baseline_posts
+			FROM tag_posts
+			GROUP BY tag
+		), scored AS (
+			SELECT tag, posts, baseline_posts, baseline_posts * $1::float8 / $2::float8 AS expected
+			FROM counts
+			WHERE posts >= $3
+				AND tag NOT IN (SELECT d.tag FROM tag_denylist d)
+		)
+		SELECT tag, posts, baseline_posts, expected, (posts - expected) / sqrt(expected + 1) AS score
+		FROM scored
+		ORDER BY score DESC, posts DESC, tag
+		LIMIT $4
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), baseline.Seconds(), minPosts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []dto.TrendingTag{}
+	for rows.Next() {
+		var t dto.TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Posts, &t.BaselinePosts, &t.Expected, &t.Score); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// Autocomplete returns the tags starting with the prefix, most used first. Denied tags are
+// left out.
+func (s *TagStore) Autocomplete(ctx context.Context, prefix string, limit int) ([]dto.Tag, error) {
+	query := `
+		SELECT t.name, t.posts_count
+		FROM tags t
+		WHERE t.name LIKE $1 ESCAPE '\' AND t.posts_count > 0
+			AND NOT EXISTS (SELECT 1 FROM tag_denylist d WHERE d.tag = t.name)
+		ORDER BY t.posts_count DESC, t.name
+		LIMIT $2
+	`
+
+	pattern := likeEscaper.Replace(prefix) + "%"
+
+	rows, err := s.db.QueryContext(ctx, query, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []dto.Tag{}
+	for rows.Next() {
+		var t dto.Tag
+		if err := rows.Scan(&t.Name, &t.PostsCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// DenyTag adds the tag, normalized by utils.NormalizeTag, to the deny-list, or updates its
+// reason if it is already denied.
+func (s *TagStore) DenyTag(ctx context.Context, tag *dto.DeniedTag) error {
+	query := `
+		INSERT INTO tag_denylist (tag, reason, created_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tag) DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING tag, created_by, created_at
+	`
+
+	return s.db.QueryRowContext(ctx, query, tag.Tag, tag.Reason, tag.CreatedBy).Scan(&tag.Tag, &tag.CreatedBy, &tag.CreatedAt)
+}
+
+// AllowTag removes the tag, normalized by utils.NormalizeTag, from the deny-list.
+func (s *TagStore) AllowTag(ctx context.Context, tag string) error {
+	query := `DELETE FROM tag_denylist WHERE tag = $1`
+
+	res, err := s.db.ExecContext(ctx, query, tag)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errs.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *TagStore) GetDeniedTags(ctx context.Context) ([]dto.DeniedTag, error) {
+	query := `
+		SELECT tag, reason, created_by, created_at
+		FROM tag_denylist
+		ORDER BY tag
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []dto.DeniedTag{}
+	for rows.Next() {
+		var t dto.DeniedTag
+		if err := rows.Scan(&t.Tag, &t.Reason, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
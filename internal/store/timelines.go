@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/mafi020/social/internal/db"
+	"github.com/mafi020/social/internal/dto"
+)
+
+type TimelineStore struct {
+	db *sql.DB
+}
+
+// FanOut writes the post to the timelines of its author and of their followers. Posts of
+// authors with more than maxFollowers followers are left to be pulled at read time, in which
+// case false is returned. Fanning out a post twice is a no-op.
+func (s *TimelineStore) FanOut(ctx context.Context, post *dto.Post, maxFollowers int) (bool, error) {
+	var fannedOut bool
+
+	err := db.WithTx(ctx, s.db, func(ctx context.Context) error {
+		conn := db.Conn(ctx, s.db)
+
+		var followers int
+		if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM followers WHERE user_id = $1`, post.UserID).Scan(&followers); err != nil {
+			return err
+		}
+		if followers > maxFollowers {
+			return nil
+		}
+
+		query := `
+			INSERT INTO timelines (user_id, post_id, author_id, created_at)
+			SELECT f.follower_id, p.id, p.user_id, p.created_at
+			FROM posts p
+			JOIN followers f ON f.user_id = p.user_id
+			WHERE p.id = $1 AND p.deleted_at IS NULL
+			UNION ALL
+			SELECT p.user_id, p.id, p.user_id, p.created_at
+			FROM posts p
+			WHERE p.id = $1 AND p.deleted_at IS NULL
+			ON CONFLICT (user_id, post_id) DO NOTHING
+		`
+		if _, err := conn.ExecContext(ctx, query, post.ID); err != nil {
+			return err
+		}
+
+		if _, err := conn.ExecContext(ctx, `UPDATE posts SET fanned_out_at = NOW() WHERE id = $1`, post.ID); err != nil {
+			return err
+		}
+
+		fannedOut = true
+		return nil
+	})
+
+	return fannedOut, err
+}
+
+// Backfill copies the latest fanned out posts of the author to the user's timeline, after the
+// user followed them.
+func (s *TimelineStore) Backfill(ctx context.Context, userID, authorID int64, limit int) error {
+	query := `
+		INSERT INTO timelines (user_id, post_id, author_id, created_at)
+		SELECT $1, p.id, p.user_id, p.created_at
+		FROM posts p
+		WHERE p.user_id = $2 AND p.fanned_out_at IS NOT NULL AND p.deleted_at IS NULL
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $3
+		ON CONFLICT (user_id, post_id) DO NOTHING
+	`
+
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, query, userID, authorID, limit)
+	return err
+}
+
+// RemoveAuthor removes the posts of the author from the user's timeline, after the user
+// unfollowed them.
+func (s *TimelineStore) RemoveAuthor(ctx context.Context, userID, authorID int64) error {
+	query := `DELETE FROM timelines WHERE user_id = $1 AND author_id = $2`
+
+	_, err := db.Conn(ctx, s.db).ExecContext(ctx, query, userID, authorID)
+	return err
+}
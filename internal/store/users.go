@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 
+	"github.com/lib/pq"
 	"github.com/mafi020/social/internal/dto"
 	"github.com/mafi020/social/internal/errs"
 )
@@ -40,13 +41,13 @@ func (s *UserStore) Create(ctx context.Context, user *dto.User) error {
 }
 func (s *UserStore) GetById(ctx context.Context, userId int64) (*dto.User, error) {
 	query := `
-		SELECT id, username, email, created_at, updated_at
+		SELECT id, username, email, is_moderator, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
 	user := &dto.User{}
 
-	err := s.db.QueryRowContext(ctx, query, userId).Scan(&user.ID, &user.UserName, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, userId).Scan(&user.ID, &user.UserName, &user.Email, &user.IsModerator, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		switch {
@@ -60,13 +61,13 @@ func (s *UserStore) GetById(ctx context.Context, userId int64) (*dto.User, error
 }
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*dto.User, error) {
 	query := `
-		SELECT id, username, email, password, created_at, updated_at
+		SELECT id, username, email, password, is_moderator, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
 	user := &dto.User{}
 
-	err := s.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.UserName, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.UserName, &user.Email, &user.Password, &user.IsModerator, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		switch {
@@ -80,13 +81,13 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*dto.User, er
 }
 func (s *UserStore) GetByUsername(ctx context.Context, username string) (*dto.User, error) {
 	query := `
-		SELECT id, username, email, created_at, updated_at
+		SELECT id, username, email, is_moderator, created_at, updated_at
 		FROM users
 		WHERE username = $1
 	`
 	user := &dto.User{}
 
-	err := s.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.UserName, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.UserName, &user.Email, &user.IsModerator, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		switch {
@@ -98,6 +99,34 @@ func (s *UserStore) GetByUsername(ctx context.Context, username string) (*dto.Us
 	}
 	return user, nil
 }
+
+// GetByUsernames returns the users with the usernames, in no particular order. Unknown
+// usernames are ignored.
+func (s *UserStore) GetByUsernames(ctx context.Context, usernames []string) ([]dto.User, error) {
+	query := `
+		SELECT id, username, email, is_moderator, created_at, updated_at
+		FROM users
+		WHERE username = ANY($1::varchar[])
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []dto.User{}
+	for rows.Next() {
+		var user dto.User
+		if err := rows.Scan(&user.ID, &user.UserName, &user.Email, &user.IsModerator, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
 func (s *UserStore) IsUserUnique(ctx context.Context, email, username string) (map[string]string, error) {
 	query := `
 		SELECT email, username
@@ -159,3 +188,44 @@ func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 	}
 	return nil
 }
+
+// GetProfilesByIDs returns the public profiles of the users, as seen by viewerID, in no
+// particular order. Unknown IDs are ignored.
+func (s *UserStore) GetProfilesByIDs(ctx context.Context, viewerID int64, ids []int64) ([]dto.UserProfile, error) {
+	query := `
+		SELECT u.id, u.username, u.is_moderator, u.created_at,
+			(SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id) AS followers_count,
+			(SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id) AS following_count,
+			(SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id AND p.status = 'published' AND p.deleted_at IS NULL) AS posts_count,
+			EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $1) AS is_followed
+		FROM users u
+		WHERE u.id = ANY($2::bigint[])
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []dto.UserProfile{}
+	for rows.Next() {
+		var profile dto.UserProfile
+		err := rows.Scan(
+			&profile.ID,
+			&profile.UserName,
+			&profile.IsModerator,
+			&profile.CreatedAt,
+			&profile.FollowersCount,
+			&profile.FollowingCount,
+			&profile.PostsCount,
+			&profile.IsFollowed,
+		)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
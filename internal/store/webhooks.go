@@ -0,0 +1,269 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/errs"
+)
+
+type WebhookStore struct {
+	db *sql.DB
+}
+
+func (s *WebhookStore) CreateSubscription(ctx context.Context, sub *dto.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (user_id, url, secret, event_types)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, active, created_at, updated_at
+	`
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		sub.UserID,
+		sub.URL,
+		sub.Secret,
+		pq.Array(sub.EventTypes),
+	).Scan(
+		&sub.ID,
+		&sub.Active,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+	)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetSubscriptionByID never loads the secret, it is only shown once at creation.
+func (s *WebhookStore) GetSubscriptionByID(ctx context.Context, id int64) (*dto.WebhookSubscription, error) {
+	query := `
+		SELECT id, user_id, url, event_types, active, created_at, updated_at
+		FROM webhook_subscriptions
+		WHERE id = $1
+	`
+
+	sub := &dto.WebhookSubscription{}
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&sub.ID,
+		&sub.UserID,
+		&sub.URL,
+		pq.Array(&sub.EventTypes),
+		&sub.Active,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, errs.ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return sub, nil
+}
+
+func (s *WebhookStore) GetSubscriptionsByUserID(ctx context.Context, userID int64) ([]dto.WebhookSubscription, error) {
+	query := `
+		SELECT id, user_id, url, event_types, active, created_at, updated_at
+		FROM webhook_subscriptions
+		WHERE user_id = $1
+		ORDER BY id DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []dto.WebhookSubscription{}
+	for rows.Next() {
+		var sub dto.WebhookSubscription
+		err := rows.Scan(
+			&sub.ID,
+			&sub.UserID,
+			&sub.URL,
+			pq.Array(&sub.EventTypes),
+			&sub.Active,
+			&sub.CreatedAt,
+			&sub.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+func (s *WebhookStore) DeleteSubscription(ctx context.Context, userID, subscriptionID int64) error {
+	query := `DELETE FROM webhook_subscriptions WHERE id = $1 AND user_id = $2`
+
+	res, err := s.db.ExecContext(ctx, query, subscriptionID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errs.ErrNotFound
+	}
+	return nil
+}
+
+// Enqueue creates one pending delivery per active subscription listening to the event type.
+// Subscriptions of users who are no longer moderators receive nothing.
+func (s *WebhookStore) Enqueue(ctx context.Context, eventType string, payload []byte) (int64, error) {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_type, payload)
+		SELECT ws.id, $1::text, $2::jsonb
+		FROM webhook_subscriptions ws
+		JOIN users u ON u.id = ws.user_id
+		WHERE ws.active AND u.is_moderator AND $1::text = ANY(ws.event_types)
+	`
+
+	res, err := s.db.ExecContext(ctx, query, eventType, payload)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ClaimDue picks pending deliveries whose next attempt is due and pushes their next attempt
+// back by the lease, so other workers skip them while they are being sent.
+func (s *WebhookStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]dto.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id
+			AND d.id IN (
+				SELECT id
+				FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING d.id, d.subscription_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.created_at, s.url, s.secret
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []dto.WebhookDelivery{}
+	for rows.Next() {
+		var d dto.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(
+			&d.ID,
+			&d.SubscriptionID,
+			&d.EventType,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.CreatedAt,
+			&d.URL,
+			&d.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt saves the outcome of a delivery attempt.
+func (s *WebhookStore) RecordAttempt(ctx context.Context, d *dto.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, response_status = $3, last_error = $4, next_attempt_at = $5, delivered_at = $6
+		WHERE id = $7
+	`
+
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		d.Status,
+		d.Attempts,
+		d.ResponseStatus,
+		d.LastError,
+		d.NextAttemptAt,
+		d.DeliveredAt,
+		d.ID,
+	)
+	return err
+}
+
+func (s *WebhookStore) GetDeliveriesBySubscriptionID(ctx context.Context, subscriptionID int64, params dto.WebhookDeliveryQueryParams) ([]dto.WebhookDelivery, error) {
+	query := `
+		SELECT id, subscription_id, event_type, payload, status, attempts, response_status, last_error, next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1
+			AND ($2::bigint = 0 OR id < $2::bigint)
+		ORDER BY id DESC
+		LIMIT $3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, subscriptionID, params.Cursor, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []dto.WebhookDelivery{}
+	for rows.Next() {
+		var d dto.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(
+			&d.ID,
+			&d.SubscriptionID,
+			&d.EventType,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseStatus,
+			&d.LastError,
+			&d.NextAttemptAt,
+			&d.DeliveredAt,
+			&d.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,254 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Event types pushed to connected clients.
+const (
+	EventPostCreated    = "post.created"
+	EventPostDeleted    = "post.deleted"
+	EventCommentCreated = "comment.created"
+	EventCommentUpdated = "comment.updated"
+	EventCommentDeleted = "comment.deleted"
+	EventNotification   = "notification"
+	EventTyping         = "typing"
+)
+
+type Event struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Broker carries events between API replicas. When the hub has a broker, published events
+// go through it and come back to every replica (this one included) via Hub.Dispatch.
+type Broker interface {
+	Publish(ctx context.Context, events []Event) error
+	Close() error
+}
+
+// Topic kinds, topics are written as "<kind>:<id>".
+const (
+	TopicUser = "user"
+	TopicPost = "post"
+)
+
+var ErrInvalidTopic = errors.New("invalid topic")
+
+func UserTopic(userID int64) string {
+	return fmt.Sprintf("%s:%d", TopicUser, userID)
+}
+
+func PostTopic(postID int64) string {
+	return fmt.Sprintf("%s:%d", TopicPost, postID)
+}
+
+// ParseTopic splits a topic into its kind and id.
+func ParseTopic(topic string) (string, int64, error) {
+	kind, rawID, ok := strings.Cut(topic, ":")
+	if !ok || (kind != TopicUser && kind != TopicPost) {
+		return "", 0, ErrInvalidTopic
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil || id < 1 {
+		return "", 0, ErrInvalidTopic
+	}
+	return kind, id, nil
+}
+
+// Hub is an in-process topic based pub/sub.
+type Hub struct {
+	mu         sync.RWMutex
+	topics     map[string]map[*Subscription]struct{}
+	broker     Broker
+	bufferSize int
+	closed     bool
+}
+
+func NewHub(bufferSize int) *Hub {
+	return &Hub{
+		topics:     make(map[string]map[*Subscription]struct{}),
+		bufferSize: bufferSize,
+	}
+}
+
+func (h *Hub) UseBroker(b Broker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.broker = b
+}
+
+// NewEvent builds an event for the topic with data encoded as JSON.
+func NewEvent(topic, eventType string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Topic: topic, Type: eventType, Data: raw}, nil
+}
+
+// Publish sends events to their topic subscribers, through the broker when one is configured.
+func (h *Hub) Publish(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	h.mu.RLock()
+	broker := h.broker
+	h.mu.RUnlock()
+
+	if broker != nil {
+		return broker.Publish(ctx, events)
+	}
+
+	for _, evt := range events {
+		h.Dispatch(evt)
+	}
+	return nil
+}
+
+// Dispatch delivers an event to the local subscribers of its topic. Delivery never blocks:
+// a subscriber whose buffer is full misses the event and is flagged as lagging.
+func (h *Hub) Dispatch(evt Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.topics[evt.Topic] {
+		select {
+		case sub.events <- evt:
+		default:
+			sub.lagOnce.Do(func() { close(sub.lagged) })
+		}
+	}
+}
+
+// Subscribe registers a new subscription to the given topics.
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		events: make(chan Event, h.bufferSize),
+		lagged: make(chan struct{}),
+		topics: make(map[string]struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		sub.closed = true
+		close(sub.events)
+		return sub
+	}
+
+	for _, topic := range topics {
+		h.addLocked(sub, topic)
+	}
+	return sub
+}
+
+// Close ends every subscription so long-lived connections can finish during shutdown.
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+
+	for topic, subs := range h.topics {
+		for sub := range subs {
+			if !sub.closed {
+				sub.closed = true
+				close(sub.events)
+			}
+		}
+		delete(h.topics, topic)
+	}
+
+	if h.broker != nil {
+		return h.broker.Close()
+	}
+	return nil
+}
+
+func (h *Hub) addLocked(sub *Subscription, topic string) {
+	if sub.closed {
+		return
+	}
+	subs, ok := h.topics[topic]
+	if !ok {
+		subs = make(map[*Subscription]struct{})
+		h.topics[topic] = subs
+	}
+	subs[sub] = struct{}{}
+	sub.topics[topic] = struct{}{}
+}
+
+func (h *Hub) removeLocked(sub *Subscription, topic string) {
+	if subs, ok := h.topics[topic]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	delete(sub.topics, topic)
+}
+
+type Subscription struct {
+	hub     *Hub
+	events  chan Event
+	lagged  chan struct{}
+	lagOnce sync.Once
+	topics  map[string]struct{}
+	closed  bool
+}
+
+// Events is closed when the subscription or the hub is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Lagged is closed the first time an event is dropped because the subscriber did not keep up.
+func (s *Subscription) Lagged() <-chan struct{} {
+	return s.lagged
+}
+
+func (s *Subscription) Add(topic string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.addLocked(s, topic)
+}
+
+func (s *Subscription) Remove(topic string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s, topic)
+}
+
+func (s *Subscription) Has(topic string) bool {
+	s.hub.mu.RLock()
+	defer s.hub.mu.RUnlock()
+	_, ok := s.topics[topic]
+	return ok
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	for topic := range s.topics {
+		s.hub.removeLocked(s, topic)
+	}
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
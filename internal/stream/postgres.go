@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// PostgresBroker fans events out to every API replica with LISTEN/NOTIFY.
+type PostgresBroker struct {
+	db       *sql.DB
+	listener *pq.Listener
+	channel  string
+	logger   *zap.SugaredLogger
+}
+
+func NewPostgresBroker(db *sql.DB, url, channel string, logger *zap.SugaredLogger) (*PostgresBroker, error) {
+	listener := pq.NewListener(url, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warnw("stream listener event", "event", ev, "error", err)
+		}
+	})
+
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &PostgresBroker{
+		db:       db,
+		listener: listener,
+		channel:  channel,
+		logger:   logger,
+	}, nil
+}
+
+// maxPayload is the NOTIFY payload limit of Postgres.
+const maxPayload = 8000
+
+// Publish sends all events in a single round trip. NOTIFY payloads are limited to 8000 bytes,
+// so events should carry small payloads: larger events are dropped, they would fail the whole
+// batch.
+func (b *PostgresBroker) Publish(ctx context.Context, events []Event) error {
+	payloads := make([]string, 0, len(events))
+	for _, evt := range events {
+		raw, err := json.Marshal(evt)
+		if err != nil {
+			return err
+		}
+		if len(raw) >= maxPayload {
+			b.logger.Warnw("stream event too large to be notified", "topic", evt.Topic, "type", evt.Type, "size", len(raw))
+			continue
+		}
+		payloads = append(payloads, string(raw))
+	}
+	if len(payloads) == 0 {
+		return nil
+	}
+
+	query := `SELECT pg_notify($1, payload) FROM unnest($2::text[]) AS payload`
+
+	rows, err := b.db.QueryContext(ctx, query, b.channel, pq.Array(payloads))
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Run dispatches notifications received from Postgres to the hub until the listener is closed.
+func (b *PostgresBroker) Run(hub *Hub) {
+	for n := range b.listener.NotificationChannel() {
+		// A nil notification means the connection was re-established; events sent meanwhile are lost.
+		if n == nil {
+			continue
+		}
+
+		var evt Event
+		if err := json.Unmarshal([]byte(n.Extra), &evt); err != nil {
+			b.logger.Warnw("invalid stream notification", "error", err)
+			continue
+		}
+		hub.Dispatch(evt)
+	}
+}
+
+func (b *PostgresBroker) Close() error {
+	return b.listener.Close()
+}
@@ -0,0 +1,81 @@
+package syndication
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Atom 1.0, see RFC 4287.
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Author     atomAuthor     `xml:"author"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomText       `xml:"content"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Atom renders the feed as an Atom 1.0 document. Every entry must have an author, as the feed
+// itself has none.
+func Atom(f Feed) ([]byte, error) {
+	feed := atomFeed{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  f.LastModified().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.SelfLink, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate"},
+		},
+		Entries: make([]atomEntry, 0, len(f.Entries)),
+	}
+
+	for _, e := range f.Entries {
+		entry := atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Updated:   e.Updated.UTC().Format(time.RFC3339),
+			Published: e.Published.UTC().Format(time.RFC3339),
+			Author:    atomAuthor{Name: e.Author},
+			Link:      atomLink{Href: e.Link, Rel: "alternate"},
+			Content:   atomText{Type: "text", Value: e.Content},
+		}
+		for _, c := range e.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return render(feed)
+}
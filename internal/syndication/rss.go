@@ -0,0 +1,72 @@
+package syndication
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// RSS 2.0, see https://www.rssboard.org/rss-specification. The author element requires an email
+// address, so authors are given by name with dc:creator instead, and the feed links to itself
+// with atom:link as recommended by the RSS Advisory Board.
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS renders the feed as an RSS 2.0 document, items are identified by their link.
+func RSS(f Feed) ([]byte, error) {
+	channel := rssChannel{
+		Title:         f.Title,
+		Link:          f.Link,
+		Description:   f.Description,
+		SelfLink:      atomLink{Href: f.SelfLink, Rel: "self", Type: "application/rss+xml"},
+		LastBuildDate: f.LastModified().Format(time.RFC1123Z),
+		Items:         make([]rssItem, 0, len(f.Entries)),
+	}
+
+	for _, e := range f.Entries {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Content,
+			Creator:     e.Author,
+			Categories:  e.Categories,
+			GUID:        rssGUID{IsPermaLink: true, Value: e.Link},
+			PubDate:     e.Published.Format(time.RFC1123Z),
+		})
+	}
+
+	return render(rss{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	})
+}
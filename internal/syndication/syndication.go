@@ -0,0 +1,61 @@
+// Package syndication renders lists of posts as RSS 2.0 and Atom 1.0 documents for feed
+// readers.
+package syndication
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+const (
+	RSSContentType  = "application/rss+xml; charset=utf-8"
+	AtomContentType = "application/atom+xml; charset=utf-8"
+)
+
+// Feed is the format independent content of a feed.
+type Feed struct {
+	ID          string // permanent, universally unique identifier, Atom only
+	Title       string
+	Description string
+	Link        string // the resource the feed is about
+	SelfLink    string // the URL of the feed itself
+	Updated     time.Time
+	Entries     []Entry
+}
+
+type Entry struct {
+	ID         string
+	Title      string
+	Link       string
+	Content    string // plain text
+	Author     string
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+}
+
+// LastModified returns the latest update of the feed and its entries.
+func (f Feed) LastModified() time.Time {
+	modified := f.Updated
+	for _, e := range f.Entries {
+		if e.Updated.After(modified) {
+			modified = e.Updated
+		}
+	}
+	return modified
+}
+
+func render(doc any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+// Package trash purges the deleted posts and comments once their retention period is over.
+package trash
+
+import (
+	"context"
+	"time"
+
+	"github.com/mafi020/social/internal/interfaces"
+	"go.uber.org/zap"
+)
+
+type Config struct {
+	Retention    time.Duration // how long deleted items can be restored
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// Purger deletes for good the posts and comments that have been in the trash for longer than
+// the retention period.
+type Purger struct {
+	posts    interfaces.PostsInterface
+	comments interfaces.CommentsInterface
+	logger   *zap.SugaredLogger
+	cfg      Config
+}
+
+func NewPurger(posts interfaces.PostsInterface, comments interfaces.CommentsInterface, logger *zap.SugaredLogger, cfg Config) *Purger {
+	return &Purger{
+		posts:    posts,
+		comments: comments,
+		logger:   logger,
+		cfg:      cfg,
+	}
+}
+
+// Run purges the trash until the context is canceled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.purge(ctx); err != nil && ctx.Err() == nil {
+			p.logger.Warnw("failed to purge the trash", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge deletes the expired items in batches, comments first as deleting a post deletes its
+// comments anyway.
+func (p *Purger) purge(ctx context.Context) error {
+	deletedBefore := time.Now().Add(-p.cfg.Retention)
+
+	var comments, posts int64
+	for {
+		n, err := p.comments.Purge(ctx, deletedBefore, p.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		comments += n
+		if n < int64(p.cfg.BatchSize) {
+			break
+		}
+	}
+	for {
+		n, err := p.posts.Purge(ctx, deletedBefore, p.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		posts += n
+		if n < int64(p.cfg.BatchSize) {
+			break
+		}
+	}
+
+	if comments > 0 || posts > 0 {
+		p.logger.Infow("Trash purged", "posts", posts, "comments", comments)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package trending
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/interfaces"
+	"go.uber.org/zap"
+)
+
+var ErrUnknownWindow = errors.New("unknown trending window")
+
+// Window is a sliding window over which tags are counted, compared with the Baseline period
+// preceding it.
+type Window struct {
+	Name     string
+	Duration time.Duration
+	Baseline time.Duration
+}
+
+var Windows = []Window{
+	{Name: "1h", Duration: time.Hour, Baseline: 24 * time.Hour},
+	{Name: "24h", Duration: 24 * time.Hour, Baseline: 7 * 24 * time.Hour},
+	{Name: "7d", Duration: 7 * 24 * time.Hour, Baseline: 28 * 24 * time.Hour},
+}
+
+func WindowByName(name string) (Window, bool) {
+	for _, w := range Windows {
+		if w.Name == name {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+type Result struct {
+	Window     string            `json:"window"`
+	ComputedAt time.Time         `json:"computed_at"`
+	Tags       []dto.TrendingTag `json:"tags"`
+}
+
+type Config struct {
+	RefreshInterval time.Duration
+	MinPosts        int // tags used fewer times over the window never trend
+	Limit           int // tags computed per window
+}
+
+// Service computes the trending tags of every window in the background and serves them from
+// memory.
+type Service struct {
+	store  interfaces.TagsInterface
+	logger *zap.SugaredLogger
+	cfg    Config
+
+	mu      sync.RWMutex
+	results map[string]Result
+}
+
+func NewService(store interfaces.TagsInterface, logger *zap.SugaredLogger, cfg Config) *Service {
+	return &Service{
+		store:   store,
+		logger:  logger,
+		cfg:     cfg,
+		results: make(map[string]Result),
+	}
+}
+
+// Run refreshes the trending tags until the context is canceled.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, w := range Windows {
+			if _, err := s.refresh(ctx, w); err != nil && ctx.Err() == nil {
+				s.logger.Warnw("failed to compute trending tags", "window", w.Name, "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Get returns the cached trending tags of the window, computing them if the background job
+// has not yet.
+func (s *Service) Get(ctx context.Context, window string) (Result, error) {
+	w, ok := WindowByName(window)
+	if !ok {
+		return Result{}, ErrUnknownWindow
+	}
+
+	s.mu.RLock()
+	res, ok := s.results[w.Name]
+	s.mu.RUnlock()
+	if ok {
+		return res, nil
+	}
+
+	return s.refresh(ctx, w)
+}
+
+// Suppress removes a tag, normalized by utils.NormalizeTag, from the cached results, so that
+// denying it takes effect before the next refresh.
+func (s *Service) Suppress(tag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, res := range s.results {
+		tags := make([]dto.TrendingTag, 0, len(res.Tags))
+		for _, t := range res.Tags {
+			if t.Tag != tag {
+				tags = append(tags, t)
+			}
+		}
+		res.Tags = tags
+		s.results[name] = res
+	}
+}
+
+func (s *Service) refresh(ctx context.Context, w Window) (Result, error) {
+	tags, err := s.store.Trending(ctx, w.Duration, w.Baseline, s.cfg.MinPosts, s.cfg.Limit)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Window: w.Name, ComputedAt: time.Now().UTC(), Tags: tags}
+
+	s.mu.Lock()
+	s.results[w.Name] = res
+	s.mu.Unlock()
+
+	return res, nil
+}
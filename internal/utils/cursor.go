@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeFeedCursor returns an opaque cursor for the given feed position. Backward cursors page
+// towards newer posts.
+func EncodeFeedCursor(c dto.FeedCursor, backward bool) string {
+	direction := "n"
+	if backward {
+		direction = "p"
+	}
+	raw := fmt.Sprintf("%s:%d:%d", direction, c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeFeedCursor(s string) (*dto.FeedCursor, bool, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false, ErrInvalidCursor
+	}
+
+	var (
+		direction string
+		nanos, id int64
+	)
+	if _, err := fmt.Sscanf(string(raw), "%1s:%d:%d", &direction, &nanos, &id); err != nil || id < 1 {
+		return nil, false, ErrInvalidCursor
+	}
+	if direction != "n" && direction != "p" {
+		return nil, false, ErrInvalidCursor
+	}
+
+	return &dto.FeedCursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: id}, direction == "p", nil
+}
+
+// FeedCursorOf returns the keyset position of a post.
+func FeedCursorOf(p dto.Post) (dto.FeedCursor, error) {
+	createdAt, err := time.Parse(time.RFC3339Nano, p.CreatedAt)
+	if err != nil {
+		return dto.FeedCursor{}, err
+	}
+	return dto.FeedCursor{CreatedAt: createdAt, ID: p.ID}, nil
+}
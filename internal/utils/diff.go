@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+// maxDiffCells caps the cells of the longest common subsequence table of DiffLines, which
+// takes quadratic time and memory. Past it the changed lines are replaced as a whole.
+const maxDiffCells = 1 << 20
+
+// DiffLines returns the line by line difference between a and b, from their longest common
+// subsequence of lines. Deleted lines come before the lines inserted in their place. When the
+// lines changed between the common leading and trailing lines are too many to diff (see
+// maxDiffCells), they are all deleted then inserted.
+func DiffLines(a, b string) []dto.DiffLine {
+	x, y := splitLines(a), splitLines(b)
+
+	diff := []dto.DiffLine{}
+
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		diff = append(diff, dto.DiffLine{Op: dto.DiffEqual, Text: x[prefix]})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	common := x[len(x)-suffix:]
+	x, y = x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+
+	if (len(x)+1)*(len(y)+1) > maxDiffCells {
+		for _, line := range x {
+			diff = append(diff, dto.DiffLine{Op: dto.DiffDelete, Text: line})
+		}
+		for _, line := range y {
+			diff = append(diff, dto.DiffLine{Op: dto.DiffInsert, Text: line})
+		}
+		return appendEqual(diff, common)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			diff = append(diff, dto.DiffLine{Op: dto.DiffEqual, Text: x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, dto.DiffLine{Op: dto.DiffDelete, Text: x[i]})
+			i++
+		default:
+			diff = append(diff, dto.DiffLine{Op: dto.DiffInsert, Text: y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		diff = append(diff, dto.DiffLine{Op: dto.DiffDelete, Text: x[i]})
+	}
+	for ; j < len(y); j++ {
+		diff = append(diff, dto.DiffLine{Op: dto.DiffInsert, Text: y[j]})
+	}
+
+	return appendEqual(diff, common)
+}
+
+func appendEqual(diff []dto.DiffLine, lines []string) []dto.DiffLine {
+	for _, line := range lines {
+		diff = append(diff, dto.DiffLine{Op: dto.DiffEqual, Text: line})
+	}
+	return diff
+}
+
+// DiffSets returns the values of b missing from a, and the values of a missing from b.
+func DiffSets(a, b []string) (added, removed []string) {
+	added, removed = []string{}, []string{}
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[v] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		inB[v] = true
+		if !inA[v] {
+			added = append(added, v)
+		}
+	}
+	for _, v := range a {
+		if !inB[v] {
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
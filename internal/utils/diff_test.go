@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mafi020/social/internal/dto"
+)
+
+func TestDiffLines(t *testing.T) {
+	got := DiffLines("a\nb\nc\nd", "a\nx\nc\nd\ne")
+	want := []dto.DiffLine{
+		{Op: dto.DiffEqual, Text: "a"},
+		{Op: dto.DiffDelete, Text: "b"},
+		{Op: dto.DiffInsert, Text: "x"},
+		{Op: dto.DiffEqual, Text: "c"},
+		{Op: dto.DiffEqual, Text: "d"},
+		{Op: dto.DiffInsert, Text: "e"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffLines() = %v, want %v", got, want)
+	}
+}
+
+func TestDiffLinesReplacesLargeChanges(t *testing.T) {
+	var a, b []string
+	for i := range 2000 {
+		a = append(a, "a"+strconv.Itoa(i))
+		b = append(b, "b"+strconv.Itoa(i))
+	}
+
+	got := DiffLines("head\n"+strings.Join(a, "\n")+"\ntail", "head\n"+strings.Join(b, "\n")+"\ntail")
+
+	if len(got) != 2+len(a)+len(b) {
+		t.Fatalf("got %d lines, want %d", len(got), 2+len(a)+len(b))
+	}
+	if got[0] != (dto.DiffLine{Op: dto.DiffEqual, Text: "head"}) || got[len(got)-1] != (dto.DiffLine{Op: dto.DiffEqual, Text: "tail"}) {
+		t.Errorf("common lines not kept: %v ... %v", got[0], got[len(got)-1])
+	}
+	for i, line := range got[1 : len(got)-1] {
+		op := dto.DiffDelete
+		if i >= len(a) {
+			op = dto.DiffInsert
+		}
+		if line.Op != op {
+			t.Fatalf("line %d is %v, want %v", i+1, line.Op, op)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxTagLength is the length of the VARCHAR(100) tags columns, in characters.
+const MaxTagLength = 100
+
+// NormalizeTag returns the canonical form of a tag: trimmed, without its leading #, case folded
+// and in Unicode NFC. ok is false when nothing is left of the tag or it is too long.
+func NormalizeTag(tag string) (normalized string, ok bool) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	tag = norm.NFC.String(cases.Fold().String(tag))
+
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", false
+	}
+	return tag, true
+}
+
+// NormalizeTags normalizes the tags and removes the duplicates, keeping the first occurrence.
+// Invalid tags are left out, ok is then false.
+func NormalizeTags(tags ...[]string) (normalized []string, ok bool) {
+	ok = true
+	normalized = []string{}
+	seen := make(map[string]bool)
+
+	for _, list := range tags {
+		for _, tag := range list {
+			n, valid := NormalizeTag(tag)
+			if !valid {
+				ok = false
+				continue
+			}
+			if !seen[n] {
+				seen[n] = true
+				normalized = append(normalized, n)
+			}
+		}
+	}
+
+	return normalized, ok
+}
@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Event types a subscription can listen to.
+const (
+	EventPostCreated        = "post.created"
+	EventCommentCreated     = "comment.created"
+	EventUserFollowed       = "user.followed"
+	EventInvitationAccepted = "invitation.accepted"
+)
+
+var EventTypes = []string{
+	EventPostCreated,
+	EventCommentCreated,
+	EventUserFollowed,
+	EventInvitationAccepted,
+}
+
+// Headers set on every delivery.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload is the body of every delivery.
+type Payload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+func NewPayload(eventType string, data any) ([]byte, error) {
+	return json.Marshal(Payload{
+		Event:      eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	})
+}
+
+// Sign returns the value of the signature header: "sha256=" followed by the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>". Including the timestamp lets receivers reject replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header in constant time. Receivers written in Go can use it as is.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,158 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/interfaces"
+	"go.uber.org/zap"
+)
+
+const (
+	statusPending   = "pending"
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+)
+
+type WorkerConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	// Delay before the first retry, doubled on every further attempt up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Timeout     time.Duration
+	// Lets deliveries reach loopback and private addresses, for development only.
+	AllowPrivateNetworks bool
+}
+
+// Worker sends pending deliveries and schedules retries for the failed ones.
+type Worker struct {
+	store  interfaces.WebhooksInterface
+	client *http.Client
+	logger *zap.SugaredLogger
+	cfg    WorkerConfig
+}
+
+func NewWorker(store interfaces.WebhooksInterface, logger *zap.SugaredLogger, cfg WorkerConfig) *Worker {
+	return &Worker{
+		store:  store,
+		client: newClient(cfg.Timeout, cfg.AllowPrivateNetworks),
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// Run polls for due deliveries until the context is canceled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.processBatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) processBatch(ctx context.Context) {
+	// Deliveries stay claimed for the whole HTTP timeout plus a margin.
+	deliveries, err := w.store.ClaimDue(ctx, w.cfg.BatchSize, w.cfg.Timeout+30*time.Second)
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.Warnw("failed to claim webhook deliveries", "error", err)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(d *dto.WebhookDelivery) {
+			defer wg.Done()
+			w.deliver(ctx, d)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+}
+
+func (w *Worker) deliver(ctx context.Context, d *dto.WebhookDelivery) {
+	statusCode, sendErr := w.send(ctx, d)
+
+	d.Attempts++
+	if statusCode != 0 {
+		d.ResponseStatus = &statusCode
+	}
+
+	now := time.Now()
+	if sendErr == nil {
+		d.Status = statusSucceeded
+		d.DeliveredAt = &now
+		d.LastError = nil
+	} else {
+		msg := sendErr.Error()
+		d.LastError = &msg
+		if d.Attempts >= w.cfg.MaxAttempts {
+			d.Status = statusFailed
+		} else {
+			d.Status = statusPending
+			d.NextAttemptAt = now.Add(w.backoff(d.Attempts))
+		}
+	}
+
+	// Record the outcome even if the worker is being stopped.
+	if err := w.store.RecordAttempt(context.WithoutCancel(ctx), d); err != nil {
+		w.logger.Warnw("failed to record webhook attempt", "delivery_id", d.ID, "error", err)
+	}
+}
+
+func (w *Worker) send(ctx context.Context, d *dto.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Social-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= w.cfg.MaxBackoff {
+			return w.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,260 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mafi020/social/internal/dto"
+	"github.com/mafi020/social/internal/interfaces"
+	"go.uber.org/zap"
+)
+
+// fakeStore hands out its deliveries on every claim and records the attempts.
+type fakeStore struct {
+	interfaces.WebhooksInterface
+
+	mu         sync.Mutex
+	deliveries []dto.WebhookDelivery
+	attempts   []dto.WebhookDelivery
+}
+
+func (s *fakeStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]dto.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]dto.WebhookDelivery{}, s.deliveries...), nil
+}
+
+func (s *fakeStore) RecordAttempt(ctx context.Context, d *dto.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, *d)
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == d.ID {
+			s.deliveries[i] = *d
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) lastAttempt(t *testing.T) dto.WebhookDelivery {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.attempts) == 0 {
+		t.Fatal("no attempt recorded")
+	}
+	return s.attempts[len(s.attempts)-1]
+}
+
+func newTestWorker(store *fakeStore, allowPrivateNetworks bool) *Worker {
+	return NewWorker(store, zap.NewNop().Sugar(), WorkerConfig{
+		PollInterval:         time.Second,
+		BatchSize:            10,
+		MaxAttempts:          3,
+		BaseBackoff:          30 * time.Second,
+		MaxBackoff:           time.Hour,
+		Timeout:              5 * time.Second,
+		AllowPrivateNetworks: allowPrivateNetworks,
+	})
+}
+
+func newDelivery(url string) dto.WebhookDelivery {
+	return dto.WebhookDelivery{
+		ID:        42,
+		EventType: EventPostCreated,
+		Payload:   []byte(`{"event":"post.created","data":{"id":1}}`),
+		Status:    statusPending,
+		URL:       url,
+		Secret:    "s3cret",
+	}
+}
+
+func TestWorkerSignsDeliveries(t *testing.T) {
+	var verified atomic.Bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil || !Verify("s3cret", timestamp, body, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(HeaderEvent) != EventPostCreated || r.Header.Get(HeaderDelivery) != "42" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		verified.Store(true)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{deliveries: []dto.WebhookDelivery{newDelivery(receiver.URL)}}
+	newTestWorker(store, true).processBatch(context.Background())
+
+	if !verified.Load() {
+		t.Fatal("the receiver could not verify the delivery")
+	}
+	d := store.lastAttempt(t)
+	if d.Status != statusSucceeded || d.Attempts != 1 || d.DeliveredAt == nil || d.LastError != nil {
+		t.Fatalf("got status %q after %d attempts, want a single successful attempt", d.Status, d.Attempts)
+	}
+	if d.ResponseStatus == nil || *d.ResponseStatus != http.StatusNoContent {
+		t.Fatalf("got response status %v, want %d", d.ResponseStatus, http.StatusNoContent)
+	}
+}
+
+func TestWorkerRetriesWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{deliveries: []dto.WebhookDelivery{newDelivery(receiver.URL)}}
+	worker := newTestWorker(store, true)
+
+	before := time.Now()
+	worker.processBatch(context.Background())
+
+	d := store.lastAttempt(t)
+	if d.Status != statusPending || d.Attempts != 1 || d.LastError == nil {
+		t.Fatalf("got status %q after %d attempts, want a pending retry", d.Status, d.Attempts)
+	}
+	if d.NextAttemptAt.Before(before.Add(30*time.Second)) || d.NextAttemptAt.After(time.Now().Add(30*time.Second)) {
+		t.Fatalf("got next attempt at %v, want 30s after the attempt", d.NextAttemptAt)
+	}
+
+	worker.processBatch(context.Background())
+	if d := store.lastAttempt(t); d.Status != statusPending || d.Attempts != 2 {
+		t.Fatalf("got status %q after %d attempts, want a pending retry", d.Status, d.Attempts)
+	}
+
+	worker.processBatch(context.Background())
+	if d := store.lastAttempt(t); d.Status != statusFailed || d.Attempts != 3 {
+		t.Fatalf("got status %q after %d attempts, want the delivery to fail for good", d.Status, d.Attempts)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("the receiver was called %d times, want 3", calls.Load())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	worker := newTestWorker(&fakeStore{}, true)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := worker.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWorkerRefusesPrivateDestinations(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{deliveries: []dto.WebhookDelivery{newDelivery(receiver.URL)}}
+	newTestWorker(store, false).processBatch(context.Background())
+
+	if calls.Load() != 0 {
+		t.Fatal("the delivery reached a loopback address")
+	}
+	if d := store.lastAttempt(t); d.Status != statusPending || d.LastError == nil {
+		t.Fatalf("got status %q, want a failed attempt", d.Status)
+	}
+}
+
+func TestWorkerDoesNotFollowRedirects(t *testing.T) {
+	var redirected atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected.Store(true)
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer receiver.Close()
+
+	store := &fakeStore{deliveries: []dto.WebhookDelivery{newDelivery(receiver.URL)}}
+	newTestWorker(store, true).processBatch(context.Background())
+
+	if redirected.Load() {
+		t.Fatal("the redirect was followed")
+	}
+	if d := store.lastAttempt(t); d.Status != statusPending {
+		t.Fatalf("got status %q, want a redirect to fail the attempt", d.Status)
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+	}
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://93.184.216.34/hooks", true},
+		{"http://127.0.0.1:8080/", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://[::1]/", false},
+		{"http://10.0.0.5/", false},
+	}
+	for _, tt := range tests {
+		err := ValidateURL(context.Background(), tt.url)
+		if tt.allowed && err != nil {
+			t.Errorf("ValidateURL(%s) = %v, want no error", tt.url, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrForbiddenDestination) {
+			t.Errorf("ValidateURL(%s) = %v, want ErrForbiddenDestination", tt.url, err)
+		}
+	}
+}